@@ -18,3 +18,64 @@ function template() {
     [ "$status" -eq 1 ]
     [ "${lines[0]}" = "Error: account id is required" ]
 }
+
+@test "template foo --output=csv" {
+    TEMPLATE_ACCOUNT=1 TEMPLATE_ACCESS_TOKEN=token run template foo --output=csv
+    assert_success
+    [ "${lines[0]}" = "ID,NAME,AGE" ]
+    [ "${lines[1]}" = "1,First Name,19" ]
+}
+
+@test "template config set access-token with a credential helper" {
+    export XDG_CONFIG_HOME="$BATS_TEST_TMPDIR"
+    export TOKEN_FILE="$BATS_TEST_TMPDIR/token"
+    export TEMPLATE_CREDENTIAL_HELPER="$DIR/fixtures/credential-helper.sh"
+    run template config set account=1 access-token=secret123456
+    assert_success
+    [ "$(cat "$TOKEN_FILE")" = "secret123456" ]
+    run template config get access-token --show-secrets
+    assert_output "secret123456"
+}
+
+@test "template config list masks a token kept by a credential helper" {
+    export XDG_CONFIG_HOME="$BATS_TEST_TMPDIR"
+    export TOKEN_FILE="$BATS_TEST_TMPDIR/token"
+    export TEMPLATE_CREDENTIAL_HELPER="$DIR/fixtures/credential-helper.sh"
+    run template config set account=1 access-token=secret123456
+    assert_success
+    run template config list
+    assert_success
+    assert_line --regexp '^access-token +\*\*\*\*$'
+}
+
+@test "template config get with an extended profile" {
+    export XDG_CONFIG_HOME="$BATS_TEST_TMPDIR"
+    mkdir -p "$XDG_CONFIG_HOME/template"
+    printf 'version: 1\naccount: "1"\nbase-url: https://team.example.com\n' > "$XDG_CONFIG_HOME/template/base.yaml"
+    printf 'version: 1\nextends: base\nbase-url: https://staging.example.com\n' > "$XDG_CONFIG_HOME/template/main.yaml"
+    run template config get account
+    assert_output "1"
+    run template config get base-url
+    assert_output "https://staging.example.com"
+}
+
+@test "template config init from environment variables" {
+    export XDG_CONFIG_HOME="$BATS_TEST_TMPDIR"
+    TEMPLATE_ACCOUNT=1 TEMPLATE_ACCESS_TOKEN=secret123456 TEMPLATE_BASE_URL=https://api.example.com \
+        run template config init --no-interactive --format yaml
+    assert_success
+    run template config get account
+    assert_output "1"
+    run template config get base-url
+    assert_output "https://api.example.com"
+    run template config get access-token --show-secrets
+    assert_output "secret123456"
+}
+
+@test "template config init without the required keys" {
+    export XDG_CONFIG_HOME="$BATS_TEST_TMPDIR"
+    run template config init --no-interactive --format yaml
+    assert_failure
+    assert_output --partial "missing required keys: account, access-token"
+    [ ! -e "$XDG_CONFIG_HOME/template/main.yaml" ]
+}
@@ -54,19 +54,21 @@ func InitOpts() (*Opts, error) {
 	}
 
 	return &Opts{
-		Stdin:   os.Stdin,
-		Stderr:  os.Stderr,
-		Stdout:  os.Stdout,
-		WorkDir: wd,
+		Stdin:     os.Stdin,
+		Stderr:    os.Stderr,
+		Stdout:    os.Stdout,
+		WorkDir:   wd,
+		SystemDir: pathConfigFile,
 	}, nil
 }
 
 // Opts
 type Opts struct {
-	Stdout  io.Writer
-	Stdin   io.Reader
-	Stderr  io.Writer
-	WorkDir string
+	Stdout    io.Writer
+	Stdin     io.Reader
+	Stderr    io.Writer
+	WorkDir   string
+	SystemDir string
 }
 
 // Validate
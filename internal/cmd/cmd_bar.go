@@ -18,6 +18,7 @@ package cmd
 
 import (
 	"github.com/MakeNowJust/heredoc/v2"
+	"github.com/edsonmichaque/template-cli/internal/cmd/formatter"
 	"github.com/edsonmichaque/template-cli/internal/config"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -37,14 +38,27 @@ func cmdBar(opts *Opts) *Cmd {
 			template bar
 			template bar --output=json
 			template bar --output=yaml
-			template bar --output=json --query="[].id"
+			template bar --output=csv --no-headers
+			template bar --output=template --template='{{range .}}{{.name}}={{.value}}{{"\n"}}{{end}}'
+			template bar --output=json --query="[].name"
+			template bar --show-secrets
 		`),
 		PreRunE: func(cmd *cobra.Command, args []string) error {
-			return viper.BindPFlags(cmd.Flags())
+			return cmdPreRun(
+				func() error {
+					return viper.BindPFlags(cmd.Flags())
+				},
+				func() error {
+					return flagOutput(formatter.ConfigList(nil))
+				},
+				flagQuery,
+				func() error {
+					return flagColumns(formatter.ConfigList(nil))
+				},
+			)
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			cfg, err := config.InitWithValidation()
-			if err != nil {
+			if _, err := config.InitWithValidation(); err != nil {
 				return wrapError(exitFailure, err)
 			}
 
@@ -59,7 +73,15 @@ func cmdBar(opts *Opts) *Cmd {
 				return wrapError(exitFailure, err)
 			}
 
-			cmd.Printf("%#v\n", cfg)
+			fmtOpts, err := formatOpts(cmd, cmd.Name())
+			if err != nil {
+				return wrapError(exitFailure, err)
+			}
+
+			if err := formatter.Format(cmd.OutOrStdout(), formatter.ToConfigList(config.Values(viper.GetViper())), fmtOpts); err != nil {
+				return wrapError(exitFailure, err)
+			}
+
 			cmd.Printf("%#v\n", promptResp)
 
 			return nil
@@ -68,8 +90,9 @@ func cmdBar(opts *Opts) *Cmd {
 
 	return initCmd(
 		cmd,
-		withFlagOutput(outputTable),
+		withFlagOutput(outputTable, formatter.ConfigList(nil)),
 		withFlagQuery(),
+		withFlagShowSecrets(),
 		withOpts(opts),
 	)
 }
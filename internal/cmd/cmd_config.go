@@ -20,10 +20,10 @@ import (
 	"errors"
 	"fmt"
 	"os"
-	"path/filepath"
-	"strconv"
 	"strings"
+	"text/tabwriter"
 
+	"github.com/MakeNowJust/heredoc/v2"
 	"github.com/edsonmichaque/template-cli/internal/cmd/formatter"
 	"github.com/edsonmichaque/template-cli/internal/config"
 	"github.com/spf13/cobra"
@@ -37,35 +37,66 @@ const (
 	cfgFmtYML  = "yml"
 )
 
-var (
-	configProps = map[string]struct{}{
-		optAccount:     {},
-		optBaseURL:     {},
-		optAccessToken: {},
-		optSandbox:     {},
-	}
-
-	cfgValidateFuncs = map[string]func(string) (interface{}, error){
-		optSandbox: func(value string) (interface{}, error) {
-			return strconv.ParseBool(value)
-		},
-		optAccount: func(value string) (interface{}, error) {
-			return strconv.ParseInt(value, 10, 64)
-		},
-		optBaseURL: func(value string) (interface{}, error) {
-			return value, nil
-		},
-		optAccessToken: func(value string) (interface{}, error) {
-			return value, nil
-		},
-	}
-)
-
 // cmdCfg
 func cmdCfg(opts *Opts) *Cmd {
 	cmd := &cobra.Command{
 		Use:   "config",
 		Short: "Manage configurations",
+		Long: heredoc.Docf(`
+			Manage configurations.
+
+			Each key is resolved independently, from lowest to highest precedence:
+
+			  1. built-in defaults
+			  2. system file %[1]s/config.<ext>
+			  3. profile file <user config dir>/%[2]s/<profile>.<ext>, or --%[3]s
+			  4. project file .%[2]s.<ext>, found walking up from the working directory
+			  5. %[4]s_* environment variables
+			  6. command line flags
+
+			Project files are checked into repositories, so they may only set
+			account, env, sandbox and views. A project file setting any other key,
+			such as base-url, is refused.
+
+			A profile file may inherit the values of another profile with the %[10]s
+			key, naming the profile whose file is merged just before it. Parents
+			may extend other profiles, as long as no profile extends itself.
+
+			When %[5]s is set and no access token is, the access token is read
+			from a credential helper. The helper command is run by the shell with
+			one of the verbs get, store or erase as its last argument. It receives
+			a JSON object with the profile, account, base_url and, for store,
+			access_token fields on stdin, and for get prints a JSON object with the
+			access_token field.
+
+			The %[6]s key selects the environment: %[7]s, %[8]s or one defined in
+			the %[9]s section of a configuration file, which may also override the
+			built-in ones:
+
+			  %[9]s:
+			    staging:
+			      base-url: https://api.staging.example.com
+			      api-version: v2
+			      tls:
+			        ca-file: /etc/ssl/staging-ca.pem
+			        insecure-skip-verify: false
+
+			Administrators may restrict the configuration with the policy file
+			%[1]s/policy.<ext>. Locked keys take their value from the policy
+			whatever other layers set, forbidden values are refused and, when
+			plaintext-tokens is false, access tokens cannot be stored in clear
+			text in configuration files. Commands refuse flags and saved values
+			that break the policy, and warn about files and environment variables
+			it overrides:
+
+			  locked:
+			    base-url: https://api.internal.example.com
+			  forbidden:
+			    sandbox: [false]
+			  plaintext-tokens: false
+		`, pathConfigFile, cmdName, optConfigFile, envPrefix, config.KeyCredentialHelper,
+			config.KeyEnv, config.EnvProd, config.EnvSandbox, config.KeyEnvironments,
+			config.KeyExtends),
 	}
 
 	return initCmd(
@@ -74,7 +105,15 @@ func cmdCfg(opts *Opts) *Cmd {
 		withCmd(
 			cmdCfgInit(opts),
 			cmdCfgGet(opts),
+			cmdCfgList(opts),
 			cmdCfgSet(opts),
+			cmdCfgUnset(opts),
+			cmdCfgEdit(opts),
+			cmdCfgEncrypt(opts),
+			cmdCfgDecrypt(opts),
+			cmdCfgRotatePassphrase(opts),
+			cmdCfgMigrate(opts),
+			cmdCfgProfiles(opts),
 		),
 	)
 }
@@ -84,37 +123,120 @@ func cmdCfgInit(opts *Opts) *Cmd {
 	cmd := &cobra.Command{
 		Use:   "init",
 		Short: "Initialize configuration",
-		Args:  cobra.ExactArgs(0),
+		Long: heredoc.Docf(`
+			Write the file of the active profile.
+
+			Values are taken from the file of the active profile, then from the
+			seed file given by --%[1]s, then from environment variables and
+			flags. Other sources, such as the profiles it extends, are not copied
+			into it, and its keys outside the schema, such as %[5]s, are kept.
+
+			Unless --%[2]s or --%[1]s is set, values are confirmed with prompts.
+			Without prompts, the command fails listing the required keys that are
+			missing. Replacing an existing profile file is confirmed unless
+			--%[3]s is set, and its previous content is kept in a %[4]s file.
+		`, optFromFile, optNoInteractive, optConfirm, config.BackupSuffix, config.KeyEnvironments),
+		Example: heredoc.Doc(`
+			template config init
+			template config init --no-interactive --account 1 --access-token TOKEN --format yaml
+			template config init --from-file seed.yaml --profile ci
+		`),
+		Args: cobra.ExactArgs(0),
 		PreRunE: func(cmd *cobra.Command, args []string) error {
-			return viper.BindPFlags(cmd.Flags())
+			return cmdPreRun(
+				func() error {
+					return viper.BindPFlags(cmd.Flags())
+				},
+				func() error {
+					if viper.GetString(optFormat) == "" {
+						return nil
+					}
+
+					return flagContains(optFormat, config.Formats)
+				},
+			)
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			cfg, err := config.Init(false)
+			if _, err := config.Init(false); err != nil {
+				return wrapError(exitFailure, err)
+			}
+
+			name, err := activeProfile()
+			if err != nil {
+				return wrapError(exitFailure, err)
+			}
+
+			settings, err := config.ProfileSettings(name)
+			if err != nil {
+				return wrapError(exitFailure, err)
+			}
+
+			fromFile := viper.GetString(optFromFile)
+			if fromFile != "" {
+				if err := readSeedFile(fromFile, settings); err != nil {
+					return wrapError(exitFailure, err)
+				}
+			}
+
+			if err := applyKeyEnv(settings); err != nil {
+				return wrapError(exitFailure, err)
+			}
+
+			if err := applyKeyFlags(cmd, settings); err != nil {
+				return wrapError(exitFailure, err)
+			}
+
+			format := viper.GetString(optFormat)
+
+			if fromFile == "" && !viper.GetBool(optNoInteractive) {
+				settings, format, err = execConfigPrompt(settings, format)
+				if err != nil {
+					return wrapError(exitFailure, err)
+				}
+			}
+
+			if format == "" {
+				if format, err = cfgFormat(); err != nil {
+					return wrapError(exitFailure, err)
+				}
+			}
+
+			target, err := config.ProfilePath(name, format)
 			if err != nil {
 				return wrapError(exitFailure, err)
 			}
 
-			cfg, ext, err := execConfigPrompt(cfg)
+			missing, err := config.MissingKeys(target, settings)
 			if err != nil {
 				return wrapError(exitFailure, err)
 			}
 
-			cfgDir, err := os.UserConfigDir()
+			if len(missing) > 0 {
+				return wrapError(exitFailure, config.MissingKeysError(missing))
+			}
+
+			if err := config.CheckStorePolicy(settings); err != nil {
+				return wrapError(exitFailure, err)
+			}
+
+			ok, err := confirmOverwrite(name)
 			if err != nil {
 				return wrapError(exitFailure, err)
 			}
 
-			target := filepath.Join(
-				cfgDir,
-				cmdName,
-				fmt.Sprintf(
-					"%s.%s",
-					viper.GetString(optProfile),
-					strings.ToLower(ext),
-				),
-			)
+			if !ok {
+				return nil
+			}
+
+			if err := config.StoreToken(settings); err != nil {
+				return wrapError(exitFailure, err)
+			}
 
-			if err := writeCfg(cfg, target); err != nil {
+			if err := writeCfg(settings, target); err != nil {
+				return wrapError(exitFailure, err)
+			}
+
+			if err := removeOtherProfileFile(name, target); err != nil {
 				return wrapError(exitFailure, err)
 			}
 
@@ -124,77 +246,188 @@ func cmdCfgInit(opts *Opts) *Cmd {
 
 	return initCmd(
 		cmd,
+		withFlagFormat(),
+		withFlagFromFile(),
+		withFlagCredentialHelper(),
+		withFlagConfirm(),
 		withOpts(opts),
 	)
 }
 
-// writeCfg
-func writeCfg(cfg *config.Config, dst string) error {
+// readSeedFile sets in settings the keys of the configuration file at
+// path, converted to their type and validated
+func readSeedFile(path string, settings map[string]interface{}) error {
 	v := viper.New()
+	v.SetConfigFile(path)
+
+	if err := v.ReadInConfig(); err != nil {
+		return err
+	}
+
+	for _, key := range config.Keys() {
+		if !v.IsSet(key.Name) {
+			continue
+		}
+
+		value, err := key.Parse(fmt.Sprint(v.Get(key.Name)))
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+
+		settings[key.Name] = value
+	}
+
+	return nil
+}
+
+// applyKeyEnv sets in settings the keys given as environment variables
+func applyKeyEnv(settings map[string]interface{}) error {
+	for _, key := range config.Keys() {
+		for _, env := range key.Env {
+			value := os.Getenv(env)
+			if value == "" {
+				continue
+			}
+
+			parsed, err := key.Parse(value)
+			if err != nil {
+				return fmt.Errorf("%s: %w", env, err)
+			}
 
-	v.Set(optAccount, cfg.Account)
-	v.Set(optAccessToken, cfg.AccessToken)
+			settings[key.Name] = parsed
+		}
+	}
+
+	return nil
+}
+
+// applyKeyFlags sets in settings the keys given as flags
+func applyKeyFlags(cmd *cobra.Command, settings map[string]interface{}) error {
+	for _, key := range config.Keys() {
+		flag := cmd.Flags().Lookup(key.Name)
+		if flag == nil || !flag.Changed {
+			continue
+		}
+
+		value, err := key.Parse(flag.Value.String())
+		if err != nil {
+			return err
+		}
+
+		settings[key.Name] = value
+	}
+
+	return nil
+}
+
+// confirmOverwrite asks before init replaces the existing file of profile
+// name
+func confirmOverwrite(name string) (bool, error) {
+	p, err := config.FindProfile(name)
+	if err != nil {
+		if errors.Is(err, config.ErrProfileNotFound) {
+			return true, nil
+		}
 
-	if cfg.BaseURL != "" {
-		v.Set(optBaseURL, cfg.BaseURL)
+		return false, err
 	}
 
-	if cfg.Sandbox {
-		v.Set(optSandbox, cfg.Sandbox)
+	return confirmAction(fmt.Sprintf("Do you want to overwrite %s?", p.Path))
+}
+
+// removeOtherProfileFile removes the files of profile name in another
+// format than target, so init can change the format of a profile
+func removeOtherProfileFile(name, target string) error {
+	profiles, err := config.Profiles()
+	if err != nil {
+		return err
 	}
 
-	if err := v.WriteConfigAs(dst); err != nil {
-		return wrapError(exitFailure, err)
+	for _, p := range profiles {
+		if p.Name != name || p.Path == target {
+			continue
+		}
+
+		if err := os.Remove(p.Path); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
+// writeCfg writes settings to dst, in the format of its extension
+func writeCfg(settings map[string]interface{}, dst string) error {
+	settings[config.KeyVersion] = config.FileVersion
+
+	return config.WriteFile(dst, settings)
+}
+
 // cmdCfgGet
 func cmdCfgGet(opts *Opts) *Cmd {
 	cmd := &cobra.Command{
-		Use:   "get",
-		Short: "Manage configurations",
+		Use:   "get <key>",
+		Short: "Print the effective value of a configuration key",
+		Long: heredoc.Docf(`
+			Print the effective value of a configuration key.
+
+			%s
+		`, cfgKeysDoc()),
+		Example: heredoc.Doc(`
+			template config get account
+			template config get base-url --profile sandbox
+			template config get access-token --show-secrets
+			template config get account --output=json
+		`),
 		Args: func(cmd *cobra.Command, args []string) error {
-			prop := args[0]
-
 			return cmdPreRun(
 				func() error {
 					return cobra.ExactArgs(1)(cmd, args)
 				},
 				func() error {
-					if _, ok := configProps[prop]; !ok {
-						return errors.New("not found")
-					}
-
-					return nil
+					return cfgKeyExists(args[0])
 				},
 			)
 		},
+		ValidArgsFunction: completeCfgKeys,
 		PreRunE: func(cmd *cobra.Command, args []string) error {
-			return viper.BindPFlags(cmd.Flags())
-		},
-		RunE: func(cmd *cobra.Command, args []string) error {
-			cfg := &config.Config{
-				Account:     "abc123456",
-				AccessToken: "def456789",
-				BaseURL:     "https://example.com",
-			}
-
-			resp, err := formatter.Format(
-				formatter.ToConfigList(cfg),
-				&formatter.Opts{
-					Output: formatter.Output(
-						viper.GetString(optOutput),
-					),
+			return cmdPreRun(
+				func() error {
+					return viper.BindPFlags(cmd.Flags())
+				},
+				func() error {
+					return flagOutput(formatter.Config{})
+				},
+				flagQuery,
+				func() error {
+					return flagColumns(formatter.Config{})
 				},
 			)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fmtOpts, err := formatOpts(cmd, cmd.Parent().Name())
+			if err != nil {
+				return wrapError(exitFailure, err)
+			}
+
+			key, err := config.LookupKey(args[0])
 			if err != nil {
-				return wrapError(1, err)
+				return wrapError(exitFailure, err)
+			}
+
+			cfg, err := config.Init(false)
+			if err != nil {
+				return wrapError(exitFailure, err)
+			}
+
+			if key.Name == config.KeyAccessToken {
+				if err := cfg.ResolveToken(); err != nil {
+					return wrapError(exitFailure, err)
+				}
 			}
 
-			if err := cmdPrint(cmd, resp); err != nil {
-				return wrapError(1, err)
+			if err := formatter.Format(cmd.OutOrStdout(), resolveCfgKey(key, cfg), fmtOpts); err != nil {
+				return wrapError(exitFailure, err)
 			}
 
 			return nil
@@ -203,44 +436,87 @@ func cmdCfgGet(opts *Opts) *Cmd {
 
 	return initCmd(
 		cmd,
-		withFlagOutput(outputTable),
+		withFlagOutput(outputText, formatter.Config{}),
+		withFlagQuery(),
+		withFlagShowSecrets(),
 		withOpts(opts),
 	)
 }
 
-// cmdCfgSet
-func cmdCfgSet(opts *Opts) *Cmd {
+// cmdCfgList
+func cmdCfgList(opts *Opts) *Cmd {
 	cmd := &cobra.Command{
-		Use:   "set",
-		Short: "Manage configurations",
-		Args: func(cmd *cobra.Command, args []string) error {
+		Use:   "list",
+		Short: "Print the effective value of every configuration key",
+		Example: heredoc.Doc(`
+			template config list
+			template config list --profile sandbox
+			template config list --output=yaml
+			template config list --show-origin
+		`),
+		Args: cobra.NoArgs,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
 			return cmdPreRun(
 				func() error {
-					return cobra.ExactArgs(2)(cmd, args)
+					return viper.BindPFlags(cmd.Flags())
 				},
 				func() error {
-					if _, ok := configProps[args[0]]; !ok {
-						return errors.New("not found")
-					}
-
-					return nil
+					return flagOutput(formatter.ConfigList(nil))
+				},
+				flagQuery,
+				func() error {
+					return flagColumns(formatter.ConfigList(nil))
 				},
 			)
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			validateCfg := cfgValidateFuncs[args[0]]
-			if validateCfg == nil {
-				return newError(exitFailure, "no validator found")
+			fmtOpts, err := formatOpts(cmd, cmd.Parent().Name())
+			if err != nil {
+				return wrapError(exitFailure, err)
 			}
 
-			value, err := validateCfg(args[1])
+			showOrigin := viper.GetBool(optShowOrigin)
+			if showOrigin && fmtOpts.Output == formatter.OutputTable {
+				fmtOpts.Output = formatter.OutputWide
+			}
+
+			cfg, err := config.Init(false)
 			if err != nil {
 				return wrapError(exitFailure, err)
 			}
 
-			viper.Set(args[0], value)
+			// the access token is masked unless shown, so it is only
+			// resolved when its value or origin is printed. Otherwise it is
+			// shown masked when a credential helper or the credentials
+			// file may keep it.
+			resolve := showOrigin || viper.GetBool(optShowSecrets)
+			if resolve {
+				if err := cfg.ResolveToken(); err != nil {
+					return wrapError(exitFailure, err)
+				}
+			}
 
-			if err := viper.WriteConfig(); err != nil {
+			hasToken, err := cfg.HasTokenSource()
+			if err != nil {
+				return wrapError(exitFailure, err)
+			}
+
+			keys := config.Keys()
+
+			list := make(formatter.ConfigList, 0, len(keys))
+			for _, key := range keys {
+				c := resolveCfgKey(key, cfg)
+				if key.Name == config.KeyAccessToken && c.Value == "" && hasToken && !resolve {
+					c.Value = config.Masked
+				}
+				if showOrigin {
+					c.Source, c.Origin = cfgOrigin(cmd, key, cfg)
+				}
+
+				list = append(list, c)
+			}
+
+			if err := formatter.Format(cmd.OutOrStdout(), list, fmtOpts); err != nil {
 				return wrapError(exitFailure, err)
 			}
 
@@ -250,6 +526,331 @@ func cmdCfgSet(opts *Opts) *Cmd {
 
 	return initCmd(
 		cmd,
+		withFlagOutput(outputTable, formatter.ConfigList(nil)),
+		withFlagQuery(),
+		withFlagShowSecrets(),
+		withFlagShowOrigin(),
 		withOpts(opts),
 	)
 }
+
+// resolveCfgKey returns the effective value of key, taking the access
+// token from cfg as it may come from a credential helper
+func resolveCfgKey(key config.Key, cfg *config.Config) formatter.Config {
+	value := key.Get(viper.GetViper())
+	if key.Name == config.KeyAccessToken {
+		value = cfg.AccessToken
+	}
+
+	return formatter.NewConfig(config.Value{
+		Key:   key,
+		Value: value,
+	})
+}
+
+// cfgOrigin returns the source of the effective value of key and the
+// policy, flag, environment variable or file setting it
+func cfgOrigin(cmd *cobra.Command, key config.Key, cfg *config.Config) (string, string) {
+	if policy, _ := config.CurrentPolicy(); policy.IsLocked(key.Name) {
+		return string(config.SourcePolicy), policy.Path
+	}
+
+	if flag := cmd.Flags().Lookup(key.Name); flag != nil && flag.Changed {
+		return string(config.SourceFlag), "--" + key.Name
+	}
+
+	for _, env := range key.Env {
+		if os.Getenv(env) != "" {
+			return string(config.SourceEnv), env
+		}
+	}
+
+	if source, path, ok := config.FileOrigin(key.Name); ok {
+		return string(source), path
+	}
+
+	if source, origin, ok := cfg.TokenOrigin(); ok && key.Name == config.KeyAccessToken {
+		return string(source), origin
+	}
+
+	return "", ""
+}
+
+// cfgKeyExists
+func cfgKeyExists(key string) error {
+	_, err := config.LookupKey(key)
+
+	return err
+}
+
+// cfgKeysDoc describes the keys of the schema for help texts
+func cfgKeysDoc() string {
+	var b strings.Builder
+
+	b.WriteString("Keys:\n")
+
+	tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+
+	for _, key := range config.Keys() {
+		notes := strings.Join(key.Env, ", ")
+		if key.Required {
+			notes = "required, " + notes
+		}
+
+		if key.Default != nil {
+			notes = fmt.Sprintf("default %v, %s", key.Default, notes)
+		}
+
+		fmt.Fprintf(tw, "  %s\t%s\t%s (%s)\n", key.Name, key.Type, key.Description, notes)
+	}
+
+	_ = tw.Flush()
+
+	return b.String()
+}
+
+// completeCfgKeys completes the first argument with configuration keys
+func completeCfgKeys(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	return config.KeyNames(), cobra.ShellCompDirectiveNoFileComp
+}
+
+// cmdCfgSet
+func cmdCfgSet(opts *Opts) *Cmd {
+	cmd := &cobra.Command{
+		Use:   "set (<key> <value> | <key>=<value>...)",
+		Short: "Set the value of configuration keys",
+		Long: heredoc.Docf(`
+			Set the value of configuration keys in the file of the active profile,
+			or in the file given by --%[1]s. A missing profile file is created in
+			the format given by --%[2]s, defaulting to the format of the current
+			profile. When a credential helper is configured, the access token is
+			handed to it instead of being written to the file. The previous content
+			of the file is kept in a %[4]s file.
+
+			%[3]s
+		`, optConfigFile, optFormat, cfgKeysDoc(), config.BackupSuffix),
+		Example: heredoc.Doc(`
+			template config set account 1
+			template config set account=1 sandbox=true
+			template config set base-url=https://example.com --profile staging
+		`),
+		Args:              cobra.MinimumNArgs(1),
+		ValidArgsFunction: completeCfgAssignments,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return viper.BindPFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			set, err := parseCfgAssignments(args)
+			if err != nil {
+				return wrapError(exitFailure, err)
+			}
+
+			if err := config.CheckStorePolicy(set); err != nil {
+				return wrapError(exitFailure, err)
+			}
+
+			if err := config.StoreToken(set); err != nil {
+				return wrapError(exitFailure, err)
+			}
+
+			if len(set) == 0 {
+				return nil
+			}
+
+			if err := updateCfg(set, nil); err != nil {
+				return wrapError(exitFailure, err)
+			}
+
+			return nil
+		},
+	}
+
+	return initCmd(
+		cmd,
+		withFlagFormat(),
+		withOpts(opts),
+	)
+}
+
+// cmdCfgUnset
+func cmdCfgUnset(opts *Opts) *Cmd {
+	cmd := &cobra.Command{
+		Use:   "unset <key>...",
+		Short: "Remove configuration keys",
+		Long: heredoc.Docf(`
+			Remove configuration keys from the file of the active profile, or from
+			the file given by --%[1]s, which must exist. Other layers may still set
+			them.
+		`, optConfigFile),
+		Example: heredoc.Doc(`
+			template config unset base-url
+			template config unset sandbox --profile staging
+		`),
+		Args: func(cmd *cobra.Command, args []string) error {
+			if err := cobra.MinimumNArgs(1)(cmd, args); err != nil {
+				return err
+			}
+
+			for _, arg := range args {
+				if err := cfgKeyExists(arg); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			return config.KeyNames(), cobra.ShellCompDirectiveNoFileComp
+		},
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return viper.BindPFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := cfgFileExists(); err != nil {
+				return wrapError(exitFailure, err)
+			}
+
+			for _, arg := range args {
+				if arg != config.KeyAccessToken {
+					continue
+				}
+
+				if err := config.EraseToken(); err != nil {
+					return wrapError(exitFailure, err)
+				}
+			}
+
+			if err := updateCfg(nil, args); err != nil {
+				return wrapError(exitFailure, err)
+			}
+
+			return nil
+		},
+	}
+
+	return initCmd(
+		cmd,
+		withOpts(opts),
+	)
+}
+
+// parseCfgAssignments parses either a key and a value, or any number of
+// key=value pairs, converting each value to the type of its key
+func parseCfgAssignments(args []string) (map[string]interface{}, error) {
+	var pairs [][2]string
+
+	if len(args) == 2 && !strings.Contains(args[0], "=") {
+		pairs = append(pairs, [2]string{args[0], args[1]})
+	} else {
+		for _, arg := range args {
+			key, value, ok := strings.Cut(arg, "=")
+			if !ok {
+				return nil, fmt.Errorf("invalid assignment %q, expected <key>=<value>", arg)
+			}
+
+			pairs = append(pairs, [2]string{key, value})
+		}
+	}
+
+	set := make(map[string]interface{}, len(pairs))
+
+	for _, pair := range pairs {
+		key, err := config.LookupKey(pair[0])
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := key.Parse(pair[1])
+		if err != nil {
+			return nil, err
+		}
+
+		set[key.Name] = value
+	}
+
+	return set, nil
+}
+
+// updateCfg sets and unsets keys in the configuration file given by flag,
+// or in the file of the active profile without making it current
+func updateCfg(set map[string]interface{}, unset []string) error {
+	if cfgFile := activeConfigFile(); cfgFile != "" {
+		return config.UpdateFile(cfgFile, set, unset)
+	}
+
+	name, err := activeProfile()
+	if err != nil {
+		return err
+	}
+
+	format, err := cfgFormat()
+	if err != nil {
+		return err
+	}
+
+	_, err = config.UpdateProfile(name, format, set, unset)
+
+	return err
+}
+
+// cfgFileExists returns an error when the configuration file given by
+// flag, or the file of the active profile, does not exist
+func cfgFileExists() error {
+	if cfgFile := activeConfigFile(); cfgFile != "" {
+		_, err := os.Stat(cfgFile)
+
+		return err
+	}
+
+	name, err := activeProfile()
+	if err != nil {
+		return err
+	}
+
+	_, err = config.FindProfile(name)
+
+	return err
+}
+
+// cfgFormat returns the format of new profile files: the format flag, or
+// the format of the current profile
+func cfgFormat() (string, error) {
+	if format := viper.GetString(optFormat); format != "" {
+		return format, nil
+	}
+
+	name, err := config.CurrentProfile()
+	if err != nil {
+		return "", err
+	}
+
+	p, err := config.FindProfile(name)
+	if err != nil {
+		if errors.Is(err, config.ErrProfileNotFound) {
+			return cfgFmtJSON, nil
+		}
+
+		return "", err
+	}
+
+	return p.Format, nil
+}
+
+// completeCfgAssignments completes key=value arguments with configuration
+// keys
+func completeCfgAssignments(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if strings.Contains(toComplete, "=") {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	names := config.KeyNames()
+	for i := range names {
+		names[i] += "="
+	}
+
+	return names, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+}
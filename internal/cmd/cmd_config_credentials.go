@@ -0,0 +1,319 @@
+// Copyright 2023 Edson Michaque
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/MakeNowJust/heredoc/v2"
+	"github.com/edsonmichaque/template-cli/internal/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// cmdCfgEncrypt
+func cmdCfgEncrypt(opts *Opts) *Cmd {
+	cmd := &cobra.Command{
+		Use:   "encrypt",
+		Short: "Move access tokens into the encrypted credentials file",
+		Long: heredoc.Docf(`
+			Move the access token of the active profile, or of every profile with
+			--%[1]s, out of its profile file and into the credentials file, which
+			is encrypted with a passphrase. The passphrase is read from %[2]s or
+			asked for.
+
+			Once the credentials file exists, tokens saved by config init and
+			config set are stored in it, and it is decrypted when no other layer
+			sets the access token.
+		`, optAll, config.EnvPassphrase),
+		Example: heredoc.Doc(`
+			template config encrypt
+			template config encrypt --all
+		`),
+		Args: cobra.NoArgs,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return viper.BindPFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			names, err := cfgTargetProfiles()
+			if err != nil {
+				return wrapError(exitFailure, err)
+			}
+
+			tokens := make(map[string]string)
+
+			for _, name := range names {
+				token, err := profileToken(name)
+				if err != nil {
+					return wrapError(exitFailure, err)
+				}
+
+				if token != "" {
+					tokens[name] = token
+				}
+			}
+
+			if len(tokens) == 0 {
+				return newError(exitFailure, "no access token to encrypt")
+			}
+
+			exists, err := config.HasCredentials()
+			if err != nil {
+				return wrapError(exitFailure, err)
+			}
+
+			passphrase, err := readPassphrase(config.EnvPassphrase, "Passphrase", !exists)
+			if err != nil {
+				return wrapError(exitFailure, err)
+			}
+
+			stored, err := config.ReadCredentials(passphrase)
+			if err != nil {
+				return wrapError(exitFailure, err)
+			}
+
+			for name, token := range tokens {
+				stored[name] = token
+			}
+
+			if err := config.WriteCredentials(stored, passphrase); err != nil {
+				return wrapError(exitFailure, err)
+			}
+
+			for _, name := range sortedKeys(tokens) {
+				if _, err := config.UpdateProfile(name, "", nil, []string{config.KeyAccessToken}); err != nil {
+					return wrapError(exitFailure, err)
+				}
+
+				cmd.Printf("Encrypted access token of profile %q\n", name)
+			}
+
+			return nil
+		},
+	}
+
+	return initCmd(
+		cmd,
+		withFlagAll(),
+		withOpts(opts),
+	)
+}
+
+// cmdCfgDecrypt
+func cmdCfgDecrypt(opts *Opts) *Cmd {
+	cmd := &cobra.Command{
+		Use:   "decrypt",
+		Short: "Move access tokens back into profile files",
+		Long: heredoc.Docf(`
+			Move the access token of the active profile, or of every profile with
+			--%[1]s, out of the encrypted credentials file and back into its
+			profile file in clear text. The credentials file is removed once it
+			holds no token.
+		`, optAll),
+		Example: heredoc.Doc(`
+			template config decrypt
+			template config decrypt --all
+		`),
+		Args: cobra.NoArgs,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return viper.BindPFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := requireCredentials(); err != nil {
+				return wrapError(exitFailure, err)
+			}
+
+			policy, err := config.CurrentPolicy()
+			if err != nil {
+				return wrapError(exitFailure, err)
+			}
+
+			if err := policy.CheckPlaintextToken(); err != nil {
+				return wrapError(exitFailure, err)
+			}
+
+			passphrase, err := readPassphrase(config.EnvPassphrase, "Passphrase", false)
+			if err != nil {
+				return wrapError(exitFailure, err)
+			}
+
+			stored, err := config.ReadCredentials(passphrase)
+			if err != nil {
+				return wrapError(exitFailure, err)
+			}
+
+			names := sortedKeys(stored)
+			if !viper.GetBool(optAll) {
+				name, err := activeProfile()
+				if err != nil {
+					return wrapError(exitFailure, err)
+				}
+
+				if _, ok := stored[name]; !ok {
+					return newError(exitFailure, "profile has no encrypted access token")
+				}
+
+				names = []string{name}
+			}
+
+			format, err := cfgFormat()
+			if err != nil {
+				return wrapError(exitFailure, err)
+			}
+
+			for _, name := range names {
+				set := map[string]interface{}{config.KeyAccessToken: stored[name]}
+				if _, err := config.UpdateProfile(name, format, set, nil); err != nil {
+					return wrapError(exitFailure, err)
+				}
+
+				delete(stored, name)
+
+				cmd.Printf("Decrypted access token of profile %q\n", name)
+			}
+
+			if err := config.WriteCredentials(stored, passphrase); err != nil {
+				return wrapError(exitFailure, err)
+			}
+
+			return nil
+		},
+	}
+
+	return initCmd(
+		cmd,
+		withFlagAll(),
+		withOpts(opts),
+	)
+}
+
+// cmdCfgRotatePassphrase
+func cmdCfgRotatePassphrase(opts *Opts) *Cmd {
+	cmd := &cobra.Command{
+		Use:   "rotate-passphrase",
+		Short: "Change the passphrase of the encrypted credentials file",
+		Long: heredoc.Docf(`
+			Change the passphrase of the encrypted credentials file. The current
+			passphrase is read from %[1]s and the new one from %[2]s, or asked for.
+		`, config.EnvPassphrase, envNewPassphrase),
+		Args: cobra.NoArgs,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return viper.BindPFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := requireCredentials(); err != nil {
+				return wrapError(exitFailure, err)
+			}
+
+			passphrase, err := readPassphrase(config.EnvPassphrase, "Current passphrase", false)
+			if err != nil {
+				return wrapError(exitFailure, err)
+			}
+
+			stored, err := config.ReadCredentials(passphrase)
+			if err != nil {
+				return wrapError(exitFailure, err)
+			}
+
+			newPassphrase, err := readPassphrase(envNewPassphrase, "New passphrase", true)
+			if err != nil {
+				return wrapError(exitFailure, err)
+			}
+
+			if err := config.WriteCredentials(stored, newPassphrase); err != nil {
+				return wrapError(exitFailure, err)
+			}
+
+			cmd.Println("Passphrase changed")
+
+			return nil
+		},
+	}
+
+	return initCmd(cmd, withOpts(opts))
+}
+
+// cfgTargetProfiles returns every profile when the all flag is set, or
+// else the active profile
+func cfgTargetProfiles() ([]string, error) {
+	if !viper.GetBool(optAll) {
+		name, err := activeProfile()
+		if err != nil {
+			return nil, err
+		}
+
+		return []string{name}, nil
+	}
+
+	profiles, err := config.Profiles()
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(profiles))
+	for _, p := range profiles {
+		names = append(names, p.Name)
+	}
+
+	return names, nil
+}
+
+// profileToken returns the access token stored in clear text in the
+// profile file of name
+func profileToken(name string) (string, error) {
+	values, err := config.ReadProfile(name)
+	if err != nil {
+		return "", err
+	}
+
+	for _, v := range values {
+		if v.Key.Name == config.KeyAccessToken {
+			token, _ := v.Value.(string)
+
+			return token, nil
+		}
+	}
+
+	return "", nil
+}
+
+// requireCredentials fails when the encrypted credentials file does not
+// exist
+func requireCredentials() error {
+	ok, err := config.HasCredentials()
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return errors.New("no encrypted credentials file, see config encrypt")
+	}
+
+	return nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
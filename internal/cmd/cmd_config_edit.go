@@ -0,0 +1,220 @@
+// Copyright 2023 Edson Michaque
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/MakeNowJust/heredoc/v2"
+	"github.com/edsonmichaque/template-cli/internal/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	defaultEditor     = "vi"
+	editErrorPrefix   = "# error: "
+	envEditor         = "EDITOR"
+	envVisual         = "VISUAL"
+	editTempPattern   = "%s-edit-*.%s"
+	editTempFilePerms = 0o600
+)
+
+// cmdCfgEdit
+func cmdCfgEdit(opts *Opts) *Cmd {
+	cmd := &cobra.Command{
+		Use:   "edit",
+		Short: "Edit a configuration file",
+		Long: heredoc.Docf(`
+			Open the file of the active profile, or the file given by --%[1]s, in
+			$%[2]s or $%[3]s. The edited file is only saved once it parses and
+			passes validation. Otherwise the editor is opened again with the error
+			on top, in lines starting with %[4]q, which are removed before the
+			file is checked again. Saving the file unchanged aborts the edit. The
+			previous content of the file is kept in a %[5]s file.
+		`, optConfigFile, envVisual, envEditor, editErrorPrefix, config.BackupSuffix),
+		Example: heredoc.Doc(`
+			template config edit
+			template config edit --profile sandbox
+			EDITOR="code --wait" template config edit
+		`),
+		Args: cobra.NoArgs,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return viper.BindPFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if viper.GetBool(optNoInteractive) {
+				return newError(exitFailure, "config edit requires an interactive terminal")
+			}
+
+			path, err := cfgEditPath()
+			if err != nil {
+				return wrapError(exitFailure, err)
+			}
+
+			saved, err := editCfg(cmd, path)
+			if err != nil {
+				return wrapError(exitFailure, err)
+			}
+
+			if saved {
+				cmd.Printf("Saved %s\n", path)
+			} else {
+				cmd.Println("No changes")
+			}
+
+			return nil
+		},
+	}
+
+	return initCmd(cmd, withOpts(opts))
+}
+
+// cfgEditPath returns the file given by flag, or the file of the active
+// profile
+func cfgEditPath() (string, error) {
+	if cfgFile := activeConfigFile(); cfgFile != "" {
+		return cfgFile, nil
+	}
+
+	name, err := activeProfile()
+	if err != nil {
+		return "", err
+	}
+
+	p, err := config.FindProfile(name)
+	if err != nil {
+		return "", err
+	}
+
+	return p.Path, nil
+}
+
+// editCfg edits a copy of the file at path until it is valid, then
+// replaces the file with it. It returns false when nothing changed.
+func editCfg(cmd *cobra.Command, path string) (bool, error) {
+	original, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+
+	ext := strings.TrimPrefix(filepath.Ext(path), ".")
+
+	tmp, err := os.CreateTemp("", fmt.Sprintf(editTempPattern, cmdName, ext))
+	if err != nil {
+		return false, err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Close(); err != nil {
+		return false, err
+	}
+
+	content := original
+
+	var failed []byte
+
+	for {
+		if err := os.WriteFile(tmp.Name(), content, editTempFilePerms); err != nil {
+			return false, err
+		}
+
+		if err := runEditor(cmd, tmp.Name()); err != nil {
+			return false, err
+		}
+
+		data, err := os.ReadFile(tmp.Name())
+		if err != nil {
+			return false, err
+		}
+
+		edited := stripEditErrors(data)
+
+		if bytes.Equal(edited, original) {
+			return false, nil
+		}
+
+		if failed != nil && bytes.Equal(edited, failed) {
+			return false, fmt.Errorf("edit aborted, %s left unchanged", path)
+		}
+
+		if err := config.ValidateFile(tmp.Name()); err != nil {
+			failed = edited
+			content = append(editErrors(err), edited...)
+
+			continue
+		}
+
+		return true, config.ReplaceFile(path, edited)
+	}
+}
+
+// runEditor opens path in $VISUAL or $EDITOR, run by the shell so they
+// can hold arguments
+func runEditor(cmd *cobra.Command, path string) error {
+	editor := os.Getenv(envVisual)
+	if editor == "" {
+		editor = os.Getenv(envEditor)
+	}
+
+	if editor == "" {
+		editor = defaultEditor
+	}
+
+	c := exec.Command("sh", "-c", editor+` "$@"`, "sh", path)
+	c.Stdin = cmd.InOrStdin()
+	c.Stdout = cmd.OutOrStdout()
+	c.Stderr = cmd.ErrOrStderr()
+
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("editor %q: %w", editor, err)
+	}
+
+	return nil
+}
+
+// editErrors renders err as comment lines put on top of the edited file
+func editErrors(err error) []byte {
+	var b bytes.Buffer
+
+	for _, line := range strings.Split(err.Error(), "\n") {
+		b.WriteString(editErrorPrefix + line + "\n")
+	}
+
+	return b.Bytes()
+}
+
+// stripEditErrors removes the lines added by editErrors
+func stripEditErrors(data []byte) []byte {
+	lines := bytes.SplitAfter(data, []byte("\n"))
+
+	var b bytes.Buffer
+
+	for _, line := range lines {
+		if !bytes.HasPrefix(line, []byte(editErrorPrefix)) {
+			b.Write(line)
+		}
+	}
+
+	return b.Bytes()
+}
@@ -0,0 +1,107 @@
+// Copyright 2023 Edson Michaque
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"github.com/MakeNowJust/heredoc/v2"
+	"github.com/edsonmichaque/template-cli/internal/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// cmdCfgMigrate
+func cmdCfgMigrate(opts *Opts) *Cmd {
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Upgrade configuration files to the current version",
+		Long: heredoc.Docf(`
+			Upgrade the file of the active profile, every profile file with --%[1]s,
+			or the file given by --%[2]s, to version %[3]d. Renamed keys are
+			updated, numeric account ids are stored as strings and, when the
+			encrypted credentials file exists, access tokens are moved into it.
+			With --%[4]s the file is also converted to another format.
+
+			The original file is kept next to it with a .v<version>.bak suffix.
+		`, optAll, optConfigFile, config.FileVersion, optFormat),
+		Example: heredoc.Doc(`
+			template config migrate
+			template config migrate --all
+			template config migrate --profile sandbox --format yaml
+		`),
+		Args: cobra.NoArgs,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return viper.BindPFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format := viper.GetString(optFormat)
+
+			if cfgFile := activeConfigFile(); cfgFile != "" {
+				m, err := config.MigrateFile(cfgFile, "", format)
+				if err != nil {
+					return wrapError(exitFailure, err)
+				}
+
+				printMigration(cmd, m)
+
+				return nil
+			}
+
+			names, err := cfgTargetProfiles()
+			if err != nil {
+				return wrapError(exitFailure, err)
+			}
+
+			for _, name := range names {
+				p, err := config.FindProfile(name)
+				if err != nil {
+					return wrapError(exitFailure, err)
+				}
+
+				m, err := config.MigrateFile(p.Path, p.Name, format)
+				if err != nil {
+					return wrapError(exitFailure, err)
+				}
+
+				printMigration(cmd, m)
+			}
+
+			return nil
+		},
+	}
+
+	return initCmd(
+		cmd,
+		withFlagAll(),
+		withFlagFormat(),
+		withOpts(opts),
+	)
+}
+
+// printMigration describes the changes made by a migration
+func printMigration(cmd *cobra.Command, m *config.Migration) {
+	if !m.Migrated() {
+		cmd.Printf("%s is up to date\n", m.Path)
+
+		return
+	}
+
+	cmd.Printf("Migrated %s from version %d to %d, backup at %s\n", m.NewPath, m.From, m.To, m.Backup)
+
+	for _, change := range m.Changes {
+		cmd.Printf("  - %s\n", change)
+	}
+}
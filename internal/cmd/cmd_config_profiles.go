@@ -0,0 +1,295 @@
+// Copyright 2023 Edson Michaque
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/MakeNowJust/heredoc/v2"
+	"github.com/edsonmichaque/template-cli/internal/cmd/formatter"
+	"github.com/edsonmichaque/template-cli/internal/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// cmdCfgProfiles
+func cmdCfgProfiles(opts *Opts) *Cmd {
+	cmd := &cobra.Command{
+		Use:   "profiles",
+		Short: "Manage configuration profiles",
+		Example: heredoc.Doc(`
+			template config profiles list
+			template config profiles use sandbox
+			template config profiles show
+			template config profiles copy main sandbox
+			template config profiles rename sandbox staging
+			template config profiles delete staging --confirm
+		`),
+	}
+
+	return initCmd(
+		cmd,
+		withOpts(opts),
+		withCmd(
+			cmdCfgProfilesList(opts),
+			cmdCfgProfilesUse(opts),
+			cmdCfgProfilesShow(opts),
+			cmdCfgProfilesRename(opts),
+			cmdCfgProfilesDelete(opts),
+			cmdCfgProfilesCopy(opts),
+		),
+	)
+}
+
+// cmdCfgProfilesList
+func cmdCfgProfilesList(opts *Opts) *Cmd {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List profiles",
+		Args:  cobra.NoArgs,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return cmdPreRun(
+				func() error {
+					return viper.BindPFlags(cmd.Flags())
+				},
+				func() error {
+					return flagOutput(formatter.ProfileList(nil))
+				},
+				flagQuery,
+				func() error {
+					return flagColumns(formatter.ProfileList(nil))
+				},
+			)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			profiles, err := config.Profiles()
+			if err != nil {
+				return wrapError(exitFailure, err)
+			}
+
+			current, err := activeProfile()
+			if err != nil {
+				return wrapError(exitFailure, err)
+			}
+
+			fmtOpts, err := formatOpts(cmd, cmd.Parent().Name())
+			if err != nil {
+				return wrapError(exitFailure, err)
+			}
+
+			if err := formatter.Format(cmd.OutOrStdout(), formatter.ToProfileList(profiles, current), fmtOpts); err != nil {
+				return wrapError(exitFailure, err)
+			}
+
+			return nil
+		},
+	}
+
+	return initCmd(
+		cmd,
+		withFlagOutput(outputTable, formatter.ProfileList(nil)),
+		withFlagQuery(),
+		withOpts(opts),
+	)
+}
+
+// cmdCfgProfilesUse
+func cmdCfgProfilesUse(opts *Opts) *Cmd {
+	cmd := &cobra.Command{
+		Use:               "use <profile>",
+		Short:             "Set the current profile",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeProfiles,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if _, err := config.FindProfile(args[0]); err != nil {
+				return wrapError(exitFailure, err)
+			}
+
+			if err := config.SetCurrentProfile(args[0]); err != nil {
+				return wrapError(exitFailure, err)
+			}
+
+			cmd.Printf("Switched to profile %q\n", args[0])
+
+			return nil
+		},
+	}
+
+	return initCmd(cmd, withOpts(opts))
+}
+
+// cmdCfgProfilesShow
+func cmdCfgProfilesShow(opts *Opts) *Cmd {
+	cmd := &cobra.Command{
+		Use:               "show [profile]",
+		Short:             "Show the configuration stored in a profile",
+		Args:              cobra.MaximumNArgs(1),
+		ValidArgsFunction: completeProfiles,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return cmdPreRun(
+				func() error {
+					return viper.BindPFlags(cmd.Flags())
+				},
+				func() error {
+					return flagOutput(formatter.ConfigList(nil))
+				},
+				flagQuery,
+				func() error {
+					return flagColumns(formatter.ConfigList(nil))
+				},
+			)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name, err := activeProfile()
+			if err != nil {
+				return wrapError(exitFailure, err)
+			}
+
+			if len(args) > 0 {
+				name = args[0]
+			}
+
+			values, err := config.ReadProfile(name)
+			if err != nil {
+				return wrapError(exitFailure, err)
+			}
+
+			fmtOpts, err := formatOpts(cmd, cmd.Parent().Parent().Name())
+			if err != nil {
+				return wrapError(exitFailure, err)
+			}
+
+			if err := formatter.Format(cmd.OutOrStdout(), formatter.ToConfigList(values), fmtOpts); err != nil {
+				return wrapError(exitFailure, err)
+			}
+
+			return nil
+		},
+	}
+
+	return initCmd(
+		cmd,
+		withFlagOutput(outputTable, formatter.ConfigList(nil)),
+		withFlagQuery(),
+		withFlagShowSecrets(),
+		withOpts(opts),
+	)
+}
+
+// cmdCfgProfilesRename
+func cmdCfgProfilesRename(opts *Opts) *Cmd {
+	cmd := &cobra.Command{
+		Use:   "rename <profile> <new-name>",
+		Short: "Rename a profile",
+		Long: heredoc.Doc(`
+			Rename a profile, along with its access token. Profiles extending it
+			are changed to extend the new name.
+		`),
+		Args:              cobra.ExactArgs(2),
+		ValidArgsFunction: completeProfiles,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := config.RenameProfile(args[0], args[1]); err != nil {
+				return wrapError(exitFailure, err)
+			}
+
+			return nil
+		},
+	}
+
+	return initCmd(cmd, withOpts(opts))
+}
+
+// cmdCfgProfilesDelete
+func cmdCfgProfilesDelete(opts *Opts) *Cmd {
+	cmd := &cobra.Command{
+		Use:   "delete <profile>",
+		Short: "Delete a profile",
+		Long: heredoc.Docf(`
+			Delete a profile and its access token. A profile extended by other
+			profiles is not deleted until their %s key is changed.
+		`, config.KeyExtends),
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeProfiles,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return viper.BindPFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if _, err := config.FindProfile(args[0]); err != nil {
+				return wrapError(exitFailure, err)
+			}
+
+			ok, err := confirmAction(fmt.Sprintf("Do you want to delete profile %q?", args[0]))
+			if err != nil {
+				return wrapError(exitFailure, err)
+			}
+
+			if !ok {
+				return nil
+			}
+
+			if err := config.DeleteProfile(args[0]); err != nil {
+				return wrapError(exitFailure, err)
+			}
+
+			return nil
+		},
+	}
+
+	return initCmd(
+		cmd,
+		withFlagConfirm(),
+		withOpts(opts),
+	)
+}
+
+// cmdCfgProfilesCopy
+func cmdCfgProfilesCopy(opts *Opts) *Cmd {
+	cmd := &cobra.Command{
+		Use:               "copy <profile> <new-name>",
+		Short:             "Copy a profile",
+		Args:              cobra.ExactArgs(2),
+		ValidArgsFunction: completeProfiles,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := config.CopyProfile(args[0], args[1]); err != nil {
+				return wrapError(exitFailure, err)
+			}
+
+			return nil
+		},
+	}
+
+	return initCmd(cmd, withOpts(opts))
+}
+
+// completeProfiles completes the first argument with profile names
+func completeProfiles(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	profiles, err := config.Profiles()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	names := make([]string, 0, len(profiles))
+	for _, p := range profiles {
+		names = append(names, p.Name)
+	}
+
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
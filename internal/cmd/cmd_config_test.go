@@ -0,0 +1,154 @@
+// Copyright 2023 Edson Michaque
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/edsonmichaque/template-cli/internal/config"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+const testPassphrase = "passphrase"
+
+// runCmd runs the root command with args, reading the policy from
+// systemDir
+func runCmd(t *testing.T, systemDir string, args ...string) error {
+	t.Helper()
+
+	viper.Reset()
+
+	var out bytes.Buffer
+
+	cmd := cmdRoot(&Opts{
+		Stdin:     &bytes.Buffer{},
+		Stdout:    &out,
+		Stderr:    &out,
+		WorkDir:   t.TempDir(),
+		SystemDir: systemDir,
+	})
+	cmd.SetArgs(args)
+
+	return cmd.Execute()
+}
+
+func TestCfgSetRefusedByPolicy(t *testing.T) {
+	helper, err := filepath.Abs(filepath.Join("..", "..", "e2e", "fixtures", "credential-helper.sh"))
+	require.NoError(t, err)
+
+	type testcase struct {
+		policy      string
+		args        []string
+		helper      bool
+		credentials bool
+	}
+
+	tt := map[string]testcase{
+		"forbidden value with a credential helper": {
+			policy: "forbidden:\n  base-url: [https://evil.example.com]\n",
+			args:   []string{"base-url=https://evil.example.com", "access-token=new-token"},
+			helper: true,
+		},
+		"locked value with a credential helper": {
+			policy: "locked:\n  base-url: https://api.internal.example.com\n",
+			args:   []string{"base-url=https://evil.example.com", "access-token=new-token"},
+			helper: true,
+		},
+		"forbidden value with the credentials file": {
+			policy:      "forbidden:\n  base-url: [https://evil.example.com]\n",
+			args:        []string{"base-url=https://evil.example.com", "access-token=new-token"},
+			credentials: true,
+		},
+		"plaintext token": {
+			policy: "plaintext-tokens: false\n",
+			args:   []string{"access-token=new-token"},
+		},
+	}
+
+	for tn, tc := range tt {
+		t.Run(tn, func(t *testing.T) {
+			home := t.TempDir()
+			t.Setenv("XDG_CONFIG_HOME", home)
+			t.Setenv(config.EnvPassphrase, testPassphrase)
+
+			tokenFile := filepath.Join(home, "token")
+			require.NoError(t, os.WriteFile(tokenFile, []byte("old-token"), 0o600))
+			t.Setenv("TOKEN_FILE", tokenFile)
+
+			if tc.helper {
+				t.Setenv("TEMPLATE_CREDENTIAL_HELPER", helper)
+			}
+
+			if tc.credentials {
+				require.NoError(t, config.WriteCredentials(map[string]string{"main": "old-token"}, testPassphrase))
+			}
+
+			systemDir := t.TempDir()
+			require.NoError(t, os.WriteFile(filepath.Join(systemDir, "policy.yaml"), []byte(tc.policy), 0o600))
+
+			err := runCmd(t, systemDir, append([]string{"config", "set"}, tc.args...)...)
+			require.Error(t, err)
+
+			token, err := os.ReadFile(tokenFile)
+			require.NoError(t, err)
+			require.Equal(t, "old-token", string(token))
+
+			if ok, _ := config.HasCredentials(); ok {
+				tokens, err := config.ReadCredentials(testPassphrase)
+				require.NoError(t, err)
+				require.Equal(t, map[string]string{"main": "old-token"}, tokens)
+			}
+
+			_, err = config.FindProfile("main")
+			require.ErrorIs(t, err, config.ErrProfileNotFound)
+		})
+	}
+}
+
+func TestCfgSetTokenKeptOutOfFile(t *testing.T) {
+	helper, err := filepath.Abs(filepath.Join("..", "..", "e2e", "fixtures", "credential-helper.sh"))
+	require.NoError(t, err)
+
+	home := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", home)
+
+	tokenFile := filepath.Join(home, "token")
+	t.Setenv("TOKEN_FILE", tokenFile)
+	t.Setenv("TEMPLATE_CREDENTIAL_HELPER", helper)
+
+	// tokens handed to a credential helper are allowed when plaintext
+	// tokens are not
+	systemDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(systemDir, "policy.yaml"), []byte("plaintext-tokens: false\n"), 0o600))
+
+	require.NoError(t, runCmd(t, systemDir, "config", "set", "account=1", "access-token=new-token"))
+
+	token, err := os.ReadFile(tokenFile)
+	require.NoError(t, err)
+	require.Equal(t, "new-token", string(token))
+
+	p, err := config.FindProfile("main")
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(p.Path)
+	require.NoError(t, err)
+	require.NotContains(t, string(data), "new-token")
+}
@@ -32,7 +32,13 @@ func cmdFoo(opts *Opts) *Cmd {
 		Example: heredoc.Doc(`
 			template foo
 			template foo --output=json
+			template foo --output=jsonl
 			template foo --output=yaml
+			template foo --output=wide
+			template foo --output=csv --no-headers
+			template foo --columns=name,id --sort-by=name --reverse
+			template foo --output=template --template='{{range .}}{{.id}} {{.name}}{{"\n"}}{{end}}'
+			template foo --output=view:short
 			template foo --output=json --query="[].id"
 		`),
 		Args: cobra.NoArgs,
@@ -42,14 +48,11 @@ func cmdFoo(opts *Opts) *Cmd {
 					return viper.BindPFlags(cmd.Flags())
 				},
 				func() error {
-					return flagContains(
-						optOutput,
-						[]string{
-							outputJSON,
-							outputYAML,
-							outputTable,
-						},
-					)
+					return flagOutput(formatter.FooList(nil))
+				},
+				flagQuery,
+				func() error {
+					return flagColumns(formatter.FooList(nil))
 				},
 			)
 		},
@@ -72,18 +75,12 @@ func cmdFoo(opts *Opts) *Cmd {
 				},
 			}
 
-			fooOutput, err := formatter.Format(
-				fooList, &formatter.Opts{
-					Output: formatter.Output(
-						viper.GetString(optOutput),
-					),
-				},
-			)
+			fmtOpts, err := formatOpts(cmd, cmd.Name())
 			if err != nil {
 				return wrapError(exitFailure, err)
 			}
 
-			if err := cmdPrint(cmd, fooOutput); err != nil {
+			if err := formatter.Format(cmd.OutOrStdout(), fooList, fmtOpts); err != nil {
 				return wrapError(exitFailure, err)
 			}
 
@@ -93,7 +90,7 @@ func cmdFoo(opts *Opts) *Cmd {
 
 	return initCmd(
 		cmd,
-		withFlagOutput(outputTable),
+		withFlagOutput(outputTable, formatter.FooList(nil)),
 		withFlagQuery(),
 		withOpts(opts),
 	)
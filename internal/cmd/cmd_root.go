@@ -19,11 +19,10 @@ package cmd
 import (
 	"errors"
 	"fmt"
-	"io"
 	"os"
-	"path/filepath"
 	"strings"
 
+	"github.com/edsonmichaque/template-cli/internal/config"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -34,42 +33,44 @@ var (
 )
 
 const (
-	cmdName           = "template"
-	defaultProfile    = "main"
-	envCfgFile        = "TEMPLATE_CONFIG_FILE"
-	envCfgHome        = "XDG_CONFIG_HOME"
-	envDev            = "DEV"
-	envPrefix         = "TEMPLATE"
-	envProd           = "PROD"
-	envProfile        = "TEMPLATE_PROFILE"
-	envSandbox        = "SANDBOX"
-	optAccessToken    = "access-token"
-	optAccount        = "account"
-	optBaseURL        = "base-url"
-	optCollaboratorID = "collaborator-id"
-	optConfigFile     = "config-file"
-	optConfirm        = "confirm"
-	optDomain         = "domain"
-	optFormat         = "format"
-	optFromFile       = "from-file"
-	optOutput         = "output"
-	optPage           = "page"
-	optPerPage        = "per-page"
-	optProfile        = "profile"
-	optNoInteractive  = "no-interactive"
-	optQuery          = "query"
-	optRecordID       = "record-id"
-	optSandbox        = "sandbox"
-	outputJSON        = "json"
-	outputTable       = "table"
-	outputText        = "text"
-	outputYAML        = "yaml"
-	pathConfigFile    = "/etc/template"
+	cmdName          = "template"
+	envCfgFile       = "TEMPLATE_CONFIG_FILE"
+	envNewPassphrase = "TEMPLATE_NEW_PASSPHRASE"
+	envPrefix        = "TEMPLATE"
+	envProfile       = "TEMPLATE_PROFILE"
+	optAccessToken   = "access-token"
+	optAccount       = "account"
+	optAll           = "all"
+	optBaseURL       = "base-url"
+	optColumns       = "columns"
+	optConfigFile    = "config-file"
+	optConfirm       = "confirm"
+	optDomain        = "domain"
+	optEnv           = "env"
+	optFormat        = "format"
+	optFromFile      = "from-file"
+	optNoHeaders     = "no-headers"
+	optOutput        = "output"
+	optProfile       = "profile"
+	optNoInteractive = "no-interactive"
+	optQuery         = "query"
+	optReverse       = "reverse"
+	optSandbox       = "sandbox"
+	optShowOrigin    = "show-origin"
+	optShowSecrets   = "show-secrets"
+	optSortBy        = "sort-by"
+	optTemplate      = "template"
+	optTemplateFile  = "template-file"
+	optViews         = "views"
+	outputTable      = "table"
+	outputText       = "text"
+	outputTemplate   = "template"
+	outputViewPrefix = "view:"
+	pathConfigFile   = "/etc/template"
 )
 
 // init
 func init() {
-	cobra.OnInitialize(initCfg)
 	viperBindFlags()
 }
 
@@ -97,12 +98,31 @@ func runWithOpts(opts *Opts) error {
 func cmdRoot(opts *Opts) *Cmd {
 	cmd := &cobra.Command{
 		Use: cmdName,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkFlagsPolicy(cmd); err != nil {
+				return wrapError(exitFailure, err)
+			}
+
+			return nil
+		},
 		PreRunE: func(cmd *cobra.Command, args []string) error {
 			return viper.BindPFlags(cmd.PersistentFlags())
 		},
 		SilenceUsage: true,
 	}
 
+	cobra.OnInitialize(func() {
+		initCfg(opts)
+	})
+
+	config.SetWarnFunc(func(msg string) {
+		fmt.Fprintf(opts.Stderr, "Warning: %s\n", msg)
+	})
+
+	config.SetPassphraseFunc(func() (string, error) {
+		return readPassphrase(config.EnvPassphrase, "Passphrase", false)
+	})
+
 	return initCmd(
 		cmd,
 		withCmd(cmdFoo(opts)),
@@ -113,60 +133,74 @@ func cmdRoot(opts *Opts) *Cmd {
 	)
 }
 
-// initCfg
-func initCfg() {
-	var (
-		cfgFile string
-		cfgName string
-		cfgDir  string
-	)
+// initCfg loads the configuration files, see config.Load for their
+// precedence
+func initCfg(opts *Opts) {
+	cfgFile := activeConfigFile()
 
-	var err error
-	if configFile != "" {
-		cfgFile = configFile
-	}
+	cfgName, err := activeProfile()
+	cobra.CheckErr(err)
 
-	if path := os.Getenv(envCfgFile); path != "" && configFile == "" {
-		cfgFile = path
+	err = config.Load(config.LoadOpts{
+		ConfigFile: cfgFile,
+		Profile:    cfgName,
+		SystemDir:  opts.SystemDir,
+		WorkDir:    opts.WorkDir,
+	})
+	if err != nil {
+		fmt.Fprintln(opts.Stderr, "Found error: ", err.Error())
 	}
+}
 
-	cfgName = defaultProfile
-
-	if dir := os.Getenv(envCfgHome); dir != "" {
-		dir, err = os.UserConfigDir()
-		cobra.CheckErr(err)
+// checkFlagsPolicy refuses configuration keys given as flags with a value
+// the policy does not allow, as locked keys would silently ignore them
+func checkFlagsPolicy(cmd *cobra.Command) error {
+	policy, err := config.CurrentPolicy()
+	if err != nil {
+		return err
+	}
 
-		cfgDir = dir
-	} else {
-		if os.Getenv(envCfgHome) != "" {
-			dir := os.Getenv(envCfgHome)
-			if dir == "" {
-				dir, err = os.UserConfigDir()
-				cobra.CheckErr(err)
-			}
+	for _, key := range config.Keys() {
+		flag := cmd.Flags().Lookup(key.Name)
+		if flag == nil || !flag.Changed {
+			continue
+		}
 
-			cfgDir = filepath.Join(dir, cmdName)
+		value, err := key.Parse(flag.Value.String())
+		if err != nil {
+			return err
+		}
 
-			if env := os.Getenv(envProfile); env != "" {
-				cfgName = env
-			}
+		if err := policy.Check(key.Name, value); err != nil {
+			return err
 		}
 	}
 
-	if cfgFile != "" {
-		viper.SetConfigFile(cfgFile)
+	return nil
+}
+
+// activeConfigFile returns the configuration file selected by flag or
+// environment, if any
+func activeConfigFile() string {
+	if configFile != "" {
+		return configFile
 	}
 
-	if cfgDir != "" && cfgName != "" {
-		viper.AddConfigPath(cfgDir)
-		viper.SetConfigName(cfgName)
+	return os.Getenv(envCfgFile)
+}
+
+// activeProfile returns the profile selected by flag or environment, or
+// the current profile
+func activeProfile() (string, error) {
+	if profile != "" {
+		return profile, nil
 	}
 
-	if err := viper.ReadInConfig(); err != nil {
-		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
-			fmt.Println("Found error: ", err.Error())
-		}
+	if env := os.Getenv(envProfile); env != "" {
+		return env, nil
 	}
+
+	return config.CurrentProfile()
 }
 
 // Cmd
@@ -230,15 +264,6 @@ func convertEnvToFlag(env string) (string, error) {
 	return flag, nil
 }
 
-// cmdPrint
-func cmdPrint(cmd *cobra.Command, r io.Reader) error {
-	if _, err := io.Copy(cmd.OutOrStdout(), r); err != nil {
-		return err
-	}
-
-	return nil
-}
-
 // flagContains
 func flagContains(flag string, values []string) error {
 	flagValue := viper.GetString(flag)
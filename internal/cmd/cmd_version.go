@@ -21,6 +21,7 @@ import (
 
 	"github.com/MakeNowJust/heredoc/v2"
 	"github.com/edsonmichaque/template-cli/internal/build"
+	"github.com/edsonmichaque/template-cli/internal/config"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -35,8 +36,14 @@ func cmdVersion(opts *Opts) *Cmd {
 			return viper.BindPFlags(cmd.Flags())
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
+			env, err := config.CurrentEnvironment()
+			if err != nil {
+				return wrapError(exitFailure, err)
+			}
+
 			tpl := heredoc.Doc(`
 				Template CLI version:  %v
+				Template environment:  %v
 				Template API endpoint: %v
 				Template API version:  %v
 				OS/Arch:               %v/%v
@@ -45,8 +52,9 @@ func cmdVersion(opts *Opts) *Cmd {
 			cmd.Printf(
 				tpl,
 				build.Version,
-				"https://example.com",
-				"v1",
+				env.Name,
+				env.BaseURL,
+				env.APIVersion,
 				runtime.GOOS,
 				runtime.GOARCH,
 			)
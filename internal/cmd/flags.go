@@ -17,6 +17,7 @@
 package cmd
 
 import (
+	"github.com/edsonmichaque/template-cli/internal/config"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -27,20 +28,123 @@ func withFlagsGlobal() cmdOption {
 		cmd.PersistentFlags().Bool(optNoInteractive, false, "No interactive")
 		cmd.PersistentFlags().String(optAccessToken, "", "Access token")
 		cmd.PersistentFlags().String(optAccount, "", "Account")
-		cmd.PersistentFlags().String(optBaseURL, "", "Base URL")
-		cmd.PersistentFlags().StringVar(&profile, optProfile, defaultProfile, "Profile")
+		cmd.PersistentFlags().String(optBaseURL, "", "Base URL, replacing the one of the environment")
+		cmd.PersistentFlags().String(optEnv, "", "Environment (default is prod)")
+		cmd.PersistentFlags().StringVar(&profile, optProfile, "", "Profile (default is the current profile)")
 		cmd.PersistentFlags().StringVarP(&configFile, optConfigFile, "c", "", "Configuration file")
 
 		cmd.MarkFlagsMutuallyExclusive(optBaseURL, optSandbox)
+		cmd.MarkFlagsMutuallyExclusive(optEnv, optSandbox)
+
+		_ = cmd.RegisterFlagCompletionFunc(
+			optEnv,
+			func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+				return config.EnvironmentNames(), cobra.ShellCompDirectiveNoFileComp
+			},
+		)
 
 		viper.SetEnvPrefix(envPrefix)
 	}
 }
 
-// withFlagOutput adds output flag to command
-func withFlagOutput(value string) cmdOption {
+// withFlagOutput adds output flag to command, completing the outputs
+// supported by data, along with the flags tuning those outputs
+func withFlagOutput(value string, data interface{}) cmdOption {
 	return func(cmd *cobra.Command) {
 		cmd.Flags().StringP(optOutput, "o", value, "Output format")
+
+		withFlagNoHeaders()(cmd)
+		withFlagColumns()(cmd)
+		withFlagTemplate()(cmd)
+
+		_ = cmd.RegisterFlagCompletionFunc(
+			optOutput,
+			func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+				return outputNames(data), cobra.ShellCompDirectiveNoFileComp
+			},
+		)
+	}
+}
+
+// withFlagNoHeaders adds no-headers flag to command
+func withFlagNoHeaders() cmdOption {
+	return func(cmd *cobra.Command) {
+		cmd.Flags().Bool(optNoHeaders, false, "Omit headers from table, csv and tsv output")
+	}
+}
+
+// withFlagColumns adds column selection and sorting flags to command
+func withFlagColumns() cmdOption {
+	return func(cmd *cobra.Command) {
+		cmd.Flags().StringSlice(optColumns, nil, "Columns to show, in order")
+		cmd.Flags().String(optSortBy, "", "Column to sort rows by")
+		cmd.Flags().Bool(optReverse, false, "Reverse the order of rows")
+	}
+}
+
+// withFlagTemplate adds template flags to command
+func withFlagTemplate() cmdOption {
+	return func(cmd *cobra.Command) {
+		cmd.Flags().String(optTemplate, "", "Go template used by template output")
+		cmd.Flags().String(optTemplateFile, "", "File containing the Go template used by template output")
+
+		cmd.MarkFlagsMutuallyExclusive(optTemplate, optTemplateFile)
+	}
+}
+
+// withFlagShowSecrets adds show-secrets flag to command
+func withFlagShowSecrets() cmdOption {
+	return func(cmd *cobra.Command) {
+		cmd.Flags().Bool(optShowSecrets, false, "Show secrets such as access tokens in clear text")
+	}
+}
+
+// withFlagShowOrigin adds show-origin flag to command
+func withFlagShowOrigin() cmdOption {
+	return func(cmd *cobra.Command) {
+		cmd.Flags().Bool(optShowOrigin, false, "Show where each value comes from")
+	}
+}
+
+// withFlagConfirm adds confirm flag to command
+func withFlagConfirm() cmdOption {
+	return func(cmd *cobra.Command) {
+		cmd.Flags().Bool(optConfirm, false, "Skip confirmation prompts")
+	}
+}
+
+// withFlagAll adds all flag to command
+func withFlagAll() cmdOption {
+	return func(cmd *cobra.Command) {
+		cmd.Flags().Bool(optAll, false, "Apply to every profile")
+	}
+}
+
+// withFlagFormat adds format flag to command, for the files it creates
+func withFlagFormat() cmdOption {
+	return func(cmd *cobra.Command) {
+		cmd.Flags().String(optFormat, "", "File format of new profiles (default is the format of the current profile)")
+
+		_ = cmd.RegisterFlagCompletionFunc(
+			optFormat,
+			func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+				return config.Formats, cobra.ShellCompDirectiveNoFileComp
+			},
+		)
+	}
+}
+
+// withFlagFromFile adds from-file flag to command
+func withFlagFromFile() cmdOption {
+	return func(cmd *cobra.Command) {
+		cmd.Flags().String(optFromFile, "", "Configuration file to read values from, without prompts")
+	}
+}
+
+// withFlagCredentialHelper adds credential-helper flag to command
+func withFlagCredentialHelper() cmdOption {
+	return func(cmd *cobra.Command) {
+		cmd.Flags().String(config.KeyCredentialHelper, "", "Command storing the access token")
 	}
 }
 
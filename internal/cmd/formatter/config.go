@@ -17,94 +17,96 @@
 package formatter
 
 import (
-	"encoding/json"
 	"fmt"
 	"io"
-	"reflect"
 
 	"github.com/edsonmichaque/template-cli/internal/config"
 )
 
 type Config struct {
-	Name  string       `json:"name"`
-	Type  reflect.Type `json:"type"`
-	Value string       `json:"value"`
+	Name      string `json:"name" table:"NAME" text:"Name"`
+	Type      string `json:"type" table:"TYPE,wide" text:"Type"`
+	Value     string `json:"value" table:"VALUE" text:"Value"`
+	Source    string `json:"source,omitempty" table:"SOURCE,wide" text:"Source"`
+	Origin    string `json:"origin,omitempty" table:"ORIGIN,wide" text:"Origin"`
+	Sensitive bool   `json:"-" table:"-"`
 }
 
-func (c Config) MarshalJSON() ([]byte, error) {
-	config := struct {
-		Name  string `json:"name"`
-		Type  string `json:"type"`
-		Value string `json:"value"`
-	}{
-		Name:  c.Name,
-		Type:  fmt.Sprintf("%v", c.Type),
-		Value: c.Value,
+// NewConfig describes the value of a configuration key
+func NewConfig(v config.Value) Config {
+	c := Config{
+		Name:      v.Key.Name,
+		Type:      string(v.Key.Type),
+		Sensitive: v.Key.Sensitive,
 	}
 
-	return json.Marshal(config)
-}
+	if v.Value != nil {
+		c.Value = fmt.Sprintf("%v", v.Value)
+	}
 
-type ConfigList []Config
+	return c
+}
 
-func ToConfigList(c *config.Config) ConfigList {
-	return []Config{
-		{
-			Name:  "account",
-			Type:  reflect.TypeOf(c.Account),
-			Value: c.Account,
-		},
-		{
-			Name:  "access-token",
-			Type:  reflect.TypeOf(c.AccessToken),
-			Value: c.AccessToken,
-		},
-		{
-			Name:  "base-url",
-			Type:  reflect.TypeOf(c.BaseURL),
-			Value: c.BaseURL,
-		},
+// FormatText prints the bare value, so it can be used in scripts
+func (c Config) FormatText(w io.Writer, opts *Opts) error {
+	if opts.Query != "" {
+		return formatText(w, c, opts)
 	}
+
+	_, err := fmt.Fprintln(w, c.Value)
+
+	return err
 }
 
-func (f ConfigList) FormatJSON(opts *Opts) (io.Reader, error) {
-	return formatJSON(f, opts)
+func (c Config) FormatJSON(w io.Writer, opts *Opts) error {
+	return formatJSON(w, c, opts)
 }
 
-func (f ConfigList) FormatYAML(opts *Opts) (io.Reader, error) {
-	return formatYAML(f, opts)
+func (c Config) FormatYAML(w io.Writer, opts *Opts) error {
+	return formatYAML(w, c, opts)
 }
 
-func (f ConfigList) FormatTable(_ *Opts) (io.Reader, error) {
-	return formatTable(f)
+func (c Config) FormatTable(w io.Writer, opts *Opts) error {
+	return formatTable(w, c, opts)
 }
 
-func (f ConfigList) formatJSON(opts *Opts) ([]byte, error) {
-	return json.MarshalIndent(f, "", "  ")
+func (c Config) Redact() interface{} {
+	if c.Sensitive {
+		c.Value = config.Mask(c.Value)
+	}
+
+	return c
 }
 
-func (f ConfigList) formatHeader() []string {
-	return []string{
-		"NAME",
-		"TYPE",
-		"VALUE",
+type ConfigList []Config
+
+func ToConfigList(values []config.Value) ConfigList {
+	list := make(ConfigList, 0, len(values))
+	for _, v := range values {
+		list = append(list, NewConfig(v))
 	}
+
+	return list
 }
 
-func (f ConfigList) formatRows() []map[string]string {
-	data := make([]map[string]string, 0, len(f))
+func (f ConfigList) FormatJSON(w io.Writer, opts *Opts) error {
+	return formatJSON(w, f, opts)
+}
 
-	fooList := f
+func (f ConfigList) FormatYAML(w io.Writer, opts *Opts) error {
+	return formatYAML(w, f, opts)
+}
+
+func (f ConfigList) FormatTable(w io.Writer, opts *Opts) error {
+	return formatTable(w, f, opts)
+}
 
-	const txtLen = 10
+func (f ConfigList) Redact() interface{} {
+	redacted := make(ConfigList, 0, len(f))
 
-	for i := range fooList {
-		data = append(data, map[string]string{
-			"NAME":  fooList[i].Name,
-			"TYPE":  fmt.Sprintf("%v", fooList[i].Type),
-			"VALUE": fooList[i].Value,
-		})
+	for _, c := range f {
+		redacted = append(redacted, c.Redact().(Config))
 	}
 
-	return data
+	return redacted
 }
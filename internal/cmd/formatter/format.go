@@ -17,174 +17,355 @@
 package formatter
 
 import (
-	"bytes"
+	"encoding/csv"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
 	"strings"
 	"text/tabwriter"
+	"text/template"
 
-	"github.com/jmespath/go-jmespath"
 	"gopkg.in/yaml.v3"
 )
 
 type Output string
 
 const (
-	OutputText  = Output("text")
-	OutputTable = Output("table")
-	OutputJSON  = Output("json")
-	OutputYAML  = Output("yaml")
+	OutputText     = Output("text")
+	OutputTable    = Output("table")
+	OutputWide     = Output("wide")
+	OutputJSON     = Output("json")
+	OutputYAML     = Output("yaml")
+	OutputJSONL    = Output("jsonl")
+	OutputCSV      = Output("csv")
+	OutputTSV      = Output("tsv")
+	OutputTemplate = Output("template")
 )
 
 type Opts struct {
-	Output Output
-	Query  string
+	Output      Output
+	Query       string
+	NoHeaders   bool
+	Template    string
+	Columns     []string
+	SortBy      string
+	Reverse     bool
+	Width       int
+	ShowSecrets bool
+}
+
+type JSONLFormatter interface {
+	FormatJSONL(w io.Writer, opts *Opts) error
 }
 
 type YAMLFormatter interface {
-	FormatYAML(opts *Opts) (io.Reader, error)
+	FormatYAML(w io.Writer, opts *Opts) error
 }
 
 type JSONFormatter interface {
-	FormatJSON(opts *Opts) (io.Reader, error)
+	FormatJSON(w io.Writer, opts *Opts) error
 }
 
 type TableFormatter interface {
-	FormatTable(opts *Opts) (io.Reader, error)
+	FormatTable(w io.Writer, opts *Opts) error
 }
 
 type TextFormatter interface {
-	FormatText(opts *Opts) (io.Reader, error)
+	FormatText(w io.Writer, opts *Opts) error
 }
 
-func Format(data interface{}, opts *Opts) (io.Reader, error) {
-	if opts.Output == OutputJSON {
-		if formatter, ok := data.(JSONFormatter); ok {
-			return formatter.FormatJSON(opts)
-		}
+type CSVFormatter interface {
+	FormatCSV(w io.Writer, opts *Opts) error
+}
+
+type TSVFormatter interface {
+	FormatTSV(w io.Writer, opts *Opts) error
+}
 
-		return nil, errors.New("json formatter is not implemented")
+type TemplateFormatter interface {
+	FormatTemplate(w io.Writer, opts *Opts) error
+}
+
+// Format writes data to w in the output selected by opts. Data is either
+// a value implementing the formatter interfaces or an Iterator. Secrets
+// are masked unless opts.ShowSecrets is set.
+func Format(w io.Writer, data interface{}, opts *Opts) error {
+	f, ok := lookup(opts.Output)
+	if !ok {
+		return fmt.Errorf("invalid output %q", opts.Output)
 	}
 
-	if opts.Output == OutputYAML {
-		if formatter, ok := data.(YAMLFormatter); ok {
-			return formatter.FormatYAML(opts)
-		}
+	if !f.Supports(data) {
+		return fmt.Errorf("output %q is not supported, expected one of: %s", opts.Output, joinOutputs(Formats(data)))
+	}
 
-		return nil, errors.New("yaml formatter is not implemented")
+	if !opts.ShowSecrets {
+		data = redact(data)
 	}
 
-	if opts.Output == OutputTable {
-		if formatter, ok := data.(TableFormatter); ok {
-			return formatter.FormatTable(opts)
+	return f.Format(w, data, opts)
+}
+
+// formatJSON encodes v directly into w, streaming the items of an
+// Iterator one at a time. A query needs the whole document, so it is
+// evaluated before anything is written.
+func formatJSON(w io.Writer, v interface{}, opts *Opts) error {
+	if opts.Query != "" {
+		result, err := queryJSON(v, opts)
+		if err != nil {
+			return err
 		}
 
-		return nil, errors.New("table formatter is not implemented")
+		v = result
 	}
 
-	if opts.Output == OutputText {
-		if formatter, ok := data.(TextFormatter); ok {
-			return formatter.FormatText(opts)
-		}
+	it, ok := v.(Iterator)
+	if !ok {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
 
-		return nil, errors.New("table formatter is not implemented")
+		return enc.Encode(v)
 	}
 
-	return nil, errors.New("invalid formatter")
-}
+	sep := "\n  "
 
-func formatJSON(j jsonFormatter, opts *Opts) (io.Reader, error) {
-	data, err := j.formatJSON(opts)
-	if err != nil {
-		return nil, err
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
 	}
 
-	var result interface{}
+	err := each(it, func(item interface{}) error {
+		data, err := json.MarshalIndent(item, "  ", "  ")
+		if err != nil {
+			return err
+		}
+
+		if _, err := io.WriteString(w, sep); err != nil {
+			return err
+		}
+
+		sep = ",\n  "
+
+		_, err = w.Write(data)
 
-	err = json.Unmarshal(data, &result)
+		return err
+	})
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	if sep != "\n  " {
+		_, err = io.WriteString(w, "\n")
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = io.WriteString(w, "]\n")
+
+	return err
+}
+
+// formatJSONL writes one compact JSON document per element as soon as the
+// element is available. A query needs the whole document, so it is
+// evaluated before the elements are written.
+func formatJSONL(w io.Writer, v interface{}, opts *Opts) error {
+	if opts.Query != "" {
+		result, err := queryJSON(v, opts)
+		if err != nil {
+			return err
+		}
+
+		v = result
 	}
 
+	enc := json.NewEncoder(w)
+
+	return each(v, enc.Encode)
+}
+
+// formatYAML writes lists one item at a time, each as a single-item
+// sequence. Items go through JSON first so keys match the JSON output.
+func formatYAML(w io.Writer, v interface{}, opts *Opts) error {
 	if opts.Query != "" {
-		result, err = jmespath.Search(opts.Query, result)
+		result, err := queryJSON(v, opts)
 		if err != nil {
-			return nil, err
+			return err
 		}
+
+		v = result
+	}
+
+	if !isList(v) {
+		return encodeYAML(w, v)
 	}
 
-	out, err := json.MarshalIndent(result, "", "  ")
+	empty := true
+
+	err := each(v, func(item interface{}) error {
+		empty = false
+
+		return encodeYAML(w, []interface{}{item})
+	})
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	if empty {
+		_, err = io.WriteString(w, "[]\n")
 	}
 
-	return bytes.NewReader(out), nil
+	return err
 }
 
-func formatYAML(j jsonFormatter, opts *Opts) (io.Reader, error) {
-	data, err := j.formatJSON(opts)
+func encodeYAML(w io.Writer, v interface{}) error {
+	result, err := decodeJSON(v)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	var result interface{}
+	enc := yaml.NewEncoder(w)
 
-	err = json.Unmarshal(data, &result)
-	if err != nil {
-		return nil, err
+	if err := enc.Encode(result); err != nil {
+		return err
 	}
 
-	if opts.Query != "" {
-		result, err = jmespath.Search(opts.Query, result)
-		if err != nil {
-			return nil, err
-		}
+	return enc.Close()
+}
+
+func formatTemplate(w io.Writer, v interface{}, opts *Opts) error {
+	tpl, err := template.New(string(OutputTemplate)).Parse(opts.Template)
+	if err != nil {
+		return err
 	}
 
-	out, err := yaml.Marshal(result)
+	result, err := queryJSON(v, opts)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	return bytes.NewReader(out), nil
+	return tpl.Execute(w, result)
 }
 
-type jsonFormatter interface {
-	formatJSON(opts *Opts) ([]byte, error)
-}
+func formatTable(w io.Writer, v interface{}, opts *Opts) error {
+	t, err := prepareTable(v, opts)
+	if err != nil {
+		return err
+	}
+
+	var widths []int
+	if opts.Width > 0 {
+		widths = fitTable(t, opts.Width, opts.NoHeaders)
+	}
+
+	// cells, headers included, are truncated to the widths fitting the
+	// terminal, if any
+	line := func(cells []string) string {
+		for i := range cells {
+			if widths != nil {
+				cells[i] = truncate(cells[i], widths[i])
+			}
+		}
+
+		return strings.Join(cells, "\t")
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, tablePadding, ' ', 0)
+
+	header := t.header
+
+	if !opts.NoHeaders {
+		if _, err := fmt.Fprintln(tw, line(append([]string{}, header...))); err != nil {
+			return err
+		}
+	}
+
+	for _, v := range t.rows {
+		row := make([]string, 0, len(header))
+
+		for _, col := range header {
+			row = append(row, v[col])
+		}
+
+		if _, err := fmt.Fprintln(tw, line(row)); err != nil {
+			return err
+		}
+	}
 
-type tableFormatter interface {
-	formatHeader() []string
-	formatRows() []map[string]string
+	return tw.Flush()
 }
 
-func formatTable(t tableFormatter) (io.Reader, error) {
-	buf := new(bytes.Buffer)
-	tw := tabwriter.NewWriter(buf, 0, 0, 2, ' ', 0)
+// formatCSV streams rows as they are produced, unless the query or the
+// sorting options need the whole table first
+func formatCSV(w io.Writer, v interface{}, opts *Opts, comma rune) error {
+	cw := csv.NewWriter(w)
+	cw.Comma = comma
+
+	var header []string
+
+	writeHeader := func(cols []string) error {
+		header = cols
+
+		if opts.NoHeaders {
+			return nil
+		}
+
+		return cw.Write(header)
+	}
+
+	writeRow := func(v map[string]string) error {
+		row := make([]string, 0, len(header))
+
+		for _, col := range header {
+			row = append(row, v[col])
+		}
+
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+
+		cw.Flush()
 
-	if _, err := fmt.Fprintln(tw, strings.Join(t.formatHeader(), "\t")); err != nil {
-		return nil, err
+		return cw.Error()
 	}
 
-	for _, v := range t.formatRows() {
-		row := make([]string, 0)
+	if opts.Query != "" || opts.SortBy != "" || opts.Reverse {
+		t, err := prepareTable(v, opts)
+		if err != nil {
+			return err
+		}
+
+		if err := writeHeader(t.header); err != nil {
+			return err
+		}
 
-		for _, col := range t.formatHeader() {
-			if v, ok := v[col]; ok {
-				row = append(row, v)
+		for _, row := range t.rows {
+			if err := writeRow(row); err != nil {
+				return err
 			}
 		}
+	} else {
+		err := eachRow(v, true, func(cols []string) error {
+			cols, err := selectColumns(cols, opts.Columns)
+			if err != nil {
+				return err
+			}
 
-		if _, err := fmt.Fprintln(tw, strings.Join(row, "\t")); err != nil {
-			return nil, err
+			return writeHeader(cols)
+		}, writeRow)
+		if err != nil {
+			return err
 		}
 	}
 
-	if err := tw.Flush(); err != nil {
-		return nil, err
+	cw.Flush()
+
+	return cw.Error()
+}
+
+func formatText(w io.Writer, v interface{}, opts *Opts) error {
+	if opts.Query != "" {
+		return formatQueryText(w, v, opts)
 	}
 
-	return buf, nil
+	return structText(w, v)
 }
@@ -17,65 +17,25 @@
 package formatter
 
 import (
-	"encoding/json"
-	"fmt"
 	"io"
 )
 
 type Foo struct {
-	ID   int64  `json:"id"`
-	Name string `json:"name"`
-	Age  string `json:"age"`
+	ID   int64  `json:"id" table:"ID" text:"ID"`
+	Name string `json:"name" table:"NAME" text:"Name"`
+	Age  string `json:"age" table:"AGE" text:"Age"`
 }
 
 type FooList []Foo
 
-func (f FooList) FormatJSON(opts *Opts) (io.Reader, error) {
-	return formatJSON(f, opts)
+func (f FooList) FormatJSON(w io.Writer, opts *Opts) error {
+	return formatJSON(w, f, opts)
 }
 
-func (f FooList) FormatYAML(opts *Opts) (io.Reader, error) {
-	return formatYAML(f, opts)
+func (f FooList) FormatYAML(w io.Writer, opts *Opts) error {
+	return formatYAML(w, f, opts)
 }
 
-func (f FooList) FormatTable(_ *Opts) (io.Reader, error) {
-	return formatTable(f)
-}
-
-func (f FooList) formatJSON(opts *Opts) ([]byte, error) {
-	return json.MarshalIndent(f, "", "  ")
-}
-
-func (f FooList) formatHeader() []string {
-	return []string{
-		"ID",
-		"NAME",
-		"AGE",
-	}
-}
-
-func (f FooList) formatRows() []map[string]string {
-	data := make([]map[string]string, 0, len(f))
-
-	fooList := f
-
-	const txtLen = 10
-
-	for i := range fooList {
-		data = append(data, map[string]string{
-			"ID":   fmt.Sprintf("%d", fooList[i].ID),
-			"NAME": fooList[i].Name,
-			"AGE":  fooList[i].Age,
-		})
-	}
-
-	return data
-}
-
-func truncate(s string, length int) string {
-	if len(s) <= length {
-		return s
-	}
-
-	return s[:length] + "..."
+func (f FooList) FormatTable(w io.Writer, opts *Opts) error {
+	return formatTable(w, f, opts)
 }
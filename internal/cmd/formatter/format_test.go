@@ -0,0 +1,108 @@
+// Copyright 2023 Edson Michaque
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package formatter
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testRecord struct {
+	ID   int    `table:"ID"`
+	Note string `table:"NOTE"`
+}
+
+func TestFormatCSV(t *testing.T) {
+	type testcase struct {
+		data  interface{}
+		opts  Opts
+		comma rune
+		want  string
+	}
+
+	tt := map[string]testcase{
+		"csv quotes commas, quotes and newlines": {
+			data: []testRecord{
+				{ID: 1, Note: "plain"},
+				{ID: 2, Note: "a, b"},
+				{ID: 3, Note: `say "hi"`},
+				{ID: 4, Note: "two\nlines"},
+			},
+			opts:  Opts{Output: OutputCSV},
+			comma: ',',
+			want:  "ID,NOTE\n1,plain\n2,\"a, b\"\n3,\"say \"\"hi\"\"\"\n4,\"two\nlines\"\n",
+		},
+		"tsv quotes tabs but not commas": {
+			data: []testRecord{
+				{ID: 1, Note: "a, b"},
+				{ID: 2, Note: "a\tb"},
+			},
+			opts:  Opts{Output: OutputTSV},
+			comma: '\t',
+			want:  "ID\tNOTE\n1\ta, b\n2\t\"a\tb\"\n",
+		},
+		"no headers": {
+			data:  []testRecord{{ID: 1, Note: "plain"}},
+			opts:  Opts{Output: OutputCSV, NoHeaders: true},
+			comma: ',',
+			want:  "1,plain\n",
+		},
+		"selected columns": {
+			data:  []testRecord{{ID: 1, Note: "plain"}},
+			opts:  Opts{Output: OutputCSV, Columns: []string{"note", "id"}},
+			comma: ',',
+			want:  "NOTE,ID\nplain,1\n",
+		},
+		"sorted": {
+			data: []testRecord{
+				{ID: 10, Note: "ten"},
+				{ID: 9, Note: "nine"},
+			},
+			opts:  Opts{Output: OutputCSV, SortBy: "id"},
+			comma: ',',
+			want:  "ID,NOTE\n9,nine\n10,ten\n",
+		},
+		"query": {
+			data:  []testRecord{{ID: 1, Note: "a, b"}},
+			opts:  Opts{Output: OutputCSV, Query: "[].{note:Note}"},
+			comma: ',',
+			want:  "NOTE\n\"a, b\"\n",
+		},
+	}
+
+	for tn, tc := range tt {
+		t.Run(tn, func(t *testing.T) {
+			var buf bytes.Buffer
+
+			require.NoError(t, formatCSV(&buf, tc.data, &tc.opts, tc.comma))
+			require.Equal(t, tc.want, buf.String())
+		})
+	}
+}
+
+func TestFormatUnsupported(t *testing.T) {
+	var buf bytes.Buffer
+
+	err := Format(&buf, FooItem{}, &Opts{Output: OutputCSV})
+	require.EqualError(t, err, `output "csv" is not supported, expected one of: text, json, jsonl, yaml, template`)
+
+	err = Format(&buf, FooList(nil), &Opts{Output: Output("xml")})
+	require.EqualError(t, err, `invalid output "xml"`)
+	require.Empty(t, buf.String())
+}
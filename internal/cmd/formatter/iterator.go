@@ -0,0 +1,132 @@
+// Copyright 2023 Edson Michaque
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package formatter
+
+import (
+	"errors"
+	"io"
+	"reflect"
+)
+
+// Iterator yields the items of a listing one at a time, so output can
+// start before the whole listing is fetched
+type Iterator interface {
+	Next() bool
+	Item() interface{}
+	Err() error
+}
+
+// NewIterator returns an Iterator calling next until it returns io.EOF
+func NewIterator(next func() (interface{}, error)) Iterator {
+	return &funcIterator{next: next}
+}
+
+type funcIterator struct {
+	next func() (interface{}, error)
+	item interface{}
+	err  error
+	done bool
+}
+
+func (f *funcIterator) Next() bool {
+	if f.done {
+		return false
+	}
+
+	f.item, f.err = f.next()
+	if f.err != nil {
+		f.done = true
+		f.item = nil
+
+		if errors.Is(f.err, io.EOF) {
+			f.err = nil
+		}
+
+		return false
+	}
+
+	return true
+}
+
+func (f *funcIterator) Item() interface{} {
+	return f.item
+}
+
+func (f *funcIterator) Err() error {
+	return f.err
+}
+
+// each calls fn with every item of an Iterator or a slice, or with data
+// itself when it is neither
+func each(data interface{}, fn func(interface{}) error) error {
+	if it, ok := data.(Iterator); ok {
+		for it.Next() {
+			if err := fn(it.Item()); err != nil {
+				return err
+			}
+		}
+
+		return it.Err()
+	}
+
+	if data == nil {
+		return fn(nil)
+	}
+
+	_, values := items(data)
+
+	for _, value := range values {
+		if err := fn(value.Interface()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// collect drains an Iterator into a slice, returning other values as is
+func collect(data interface{}) (interface{}, error) {
+	if _, ok := data.(Iterator); !ok {
+		return data, nil
+	}
+
+	list := make([]interface{}, 0)
+
+	err := each(data, func(item interface{}) error {
+		list = append(list, item)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+func isList(data interface{}) bool {
+	if _, ok := data.(Iterator); ok {
+		return true
+	}
+
+	v := reflect.ValueOf(data)
+	for v.Kind() == reflect.Pointer && !v.IsNil() {
+		v = v.Elem()
+	}
+
+	return v.Kind() == reflect.Slice || v.Kind() == reflect.Array
+}
@@ -0,0 +1,167 @@
+// Copyright 2023 Edson Michaque
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package formatter
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testIterator returns an iterator over items, calling before ahead of
+// producing each of them, and ending with err if set
+func testIterator(items []interface{}, err error, before func(int)) Iterator {
+	i := 0
+
+	return NewIterator(func() (interface{}, error) {
+		if before != nil {
+			before(i)
+		}
+
+		if i == len(items) {
+			if err != nil {
+				return nil, err
+			}
+
+			return nil, io.EOF
+		}
+
+		i++
+
+		return items[i-1], nil
+	})
+}
+
+func TestIterator(t *testing.T) {
+	type testcase struct {
+		items []interface{}
+		err   error
+		want  []interface{}
+	}
+
+	tt := map[string]testcase{
+		"items": {
+			items: []interface{}{1, 2},
+			want:  []interface{}{1, 2},
+		},
+		"empty": {
+			want: []interface{}{},
+		},
+		"error": {
+			items: []interface{}{1},
+			err:   errors.New("boom"),
+		},
+	}
+
+	for tn, tc := range tt {
+		t.Run(tn, func(t *testing.T) {
+			it := testIterator(tc.items, tc.err, nil)
+
+			got, err := collect(it)
+			if tc.err != nil {
+				require.ErrorIs(t, err, tc.err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.want, got)
+			require.False(t, it.Next())
+		})
+	}
+}
+
+func TestFormatJSONLStreams(t *testing.T) {
+	var buf bytes.Buffer
+
+	items := []interface{}{
+		map[string]int{"id": 1},
+		map[string]int{"id": 2},
+	}
+
+	// every item is written before the next one is produced
+	it := testIterator(items, nil, func(i int) {
+		require.Equal(t, i, strings.Count(buf.String(), "\n"))
+	})
+
+	require.NoError(t, Format(&buf, it, &Opts{Output: OutputJSONL}))
+	require.Equal(t, "{\"id\":1}\n{\"id\":2}\n", buf.String())
+}
+
+func TestFormatIterator(t *testing.T) {
+	type testcase struct {
+		opts Opts
+		want string
+		err  error
+	}
+
+	items := []interface{}{
+		map[string]int{"id": 1},
+		map[string]int{"id": 2},
+	}
+
+	tt := map[string]testcase{
+		"json": {
+			opts: Opts{Output: OutputJSON},
+			want: "[\n  {\n    \"id\": 1\n  },\n  {\n    \"id\": 2\n  }\n]\n",
+		},
+		"jsonl with a query": {
+			opts: Opts{Output: OutputJSONL, Query: "[].id"},
+			want: "1\n2\n",
+		},
+		"yaml": {
+			opts: Opts{Output: OutputYAML},
+			want: "- id: 1\n- id: 2\n",
+		},
+		"error": {
+			opts: Opts{Output: OutputJSONL},
+			want: "{\"id\":1}\n{\"id\":2}\n",
+			err:  errors.New("boom"),
+		},
+	}
+
+	for tn, tc := range tt {
+		t.Run(tn, func(t *testing.T) {
+			var buf bytes.Buffer
+
+			err := Format(&buf, testIterator(items, tc.err, nil), &tc.opts)
+			if tc.err != nil {
+				require.ErrorIs(t, err, tc.err)
+			} else {
+				require.NoError(t, err)
+			}
+
+			require.Equal(t, tc.want, buf.String())
+		})
+	}
+}
+
+func TestFormatEmptyIterator(t *testing.T) {
+	var buf bytes.Buffer
+
+	require.NoError(t, Format(&buf, testIterator(nil, nil, nil), &Opts{Output: OutputJSON}))
+	require.Equal(t, "[]\n", buf.String())
+
+	buf.Reset()
+
+	require.NoError(t, Format(&buf, testIterator(nil, nil, nil), &Opts{Output: OutputJSONL}))
+	require.Empty(t, buf.String())
+}
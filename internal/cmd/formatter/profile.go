@@ -0,0 +1,69 @@
+// Copyright 2023 Edson Michaque
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package formatter
+
+import (
+	"io"
+
+	"github.com/edsonmichaque/template-cli/internal/config"
+)
+
+type activeMarker bool
+
+func (a activeMarker) String() string {
+	if a {
+		return "*"
+	}
+
+	return ""
+}
+
+type Profile struct {
+	Active activeMarker `json:"active" table:"CURRENT" text:"Current"`
+	Name   string       `json:"name" table:"NAME" text:"Name"`
+	Format string       `json:"format" table:"FORMAT" text:"Format"`
+	Path   string       `json:"path" table:"PATH,wide" text:"Path"`
+}
+
+type ProfileList []Profile
+
+func ToProfileList(profiles []config.Profile, current string) ProfileList {
+	list := make(ProfileList, 0, len(profiles))
+
+	for _, p := range profiles {
+		list = append(list, Profile{
+			Active: p.Name == current,
+			Name:   p.Name,
+			Format: p.Format,
+			Path:   p.Path,
+		})
+	}
+
+	return list
+}
+
+func (f ProfileList) FormatJSON(w io.Writer, opts *Opts) error {
+	return formatJSON(w, f, opts)
+}
+
+func (f ProfileList) FormatYAML(w io.Writer, opts *Opts) error {
+	return formatYAML(w, f, opts)
+}
+
+func (f ProfileList) FormatTable(w io.Writer, opts *Opts) error {
+	return formatTable(w, f, opts)
+}
@@ -0,0 +1,275 @@
+// Copyright 2023 Edson Michaque
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package formatter
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jmespath/go-jmespath"
+)
+
+const queryValueColumn = "VALUE"
+
+// ValidateQuery reports whether query is a valid JMESPath expression
+func ValidateQuery(query string) error {
+	if query == "" {
+		return nil
+	}
+
+	if _, err := jmespath.Compile(query); err != nil {
+		return fmt.Errorf("invalid query %q: %w", query, err)
+	}
+
+	return nil
+}
+
+// decodeJSON converts v into the generic value of its JSON encoding
+func decodeJSON(v interface{}) (interface{}, error) {
+	v, err := collect(v)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var result interface{}
+
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// queryJSON decodes v and applies opts.Query to it
+func queryJSON(v interface{}, opts *Opts) (interface{}, error) {
+	result, err := decodeJSON(v)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Query != "" {
+		result, err = jmespath.Search(opts.Query, result)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// queryTable builds a table from the projection of opts.Query, deriving
+// the columns from the projected result. Columns follow the fields of v,
+// then the keys the query adds, in alphabetical order.
+func queryTable(v interface{}, opts *Opts) (tableData, error) {
+	v, err := collect(v)
+	if err != nil {
+		return tableData{}, err
+	}
+
+	order, err := keyOrder(v)
+	if err != nil {
+		return tableData{}, err
+	}
+
+	result, err := queryJSON(v, opts)
+	if err != nil {
+		return tableData{}, err
+	}
+
+	items, ok := result.([]interface{})
+	if !ok {
+		items = []interface{}{result}
+	}
+
+	var q tableData
+
+	seen := make(map[string]struct{})
+
+	for _, item := range items {
+		row := make(map[string]string)
+
+		obj, ok := item.(map[string]interface{})
+		if !ok {
+			obj = map[string]interface{}{queryValueColumn: item}
+		}
+
+		for _, k := range sortedKeys(obj, order) {
+			col := strings.ToUpper(k)
+			if _, ok := seen[col]; !ok {
+				seen[col] = struct{}{}
+				q.header = append(q.header, col)
+			}
+
+			row[col] = formatValue(obj[k])
+		}
+
+		q.rows = append(q.rows, row)
+	}
+
+	return q, nil
+}
+
+func formatQueryText(w io.Writer, v interface{}, opts *Opts) error {
+	v, err := collect(v)
+	if err != nil {
+		return err
+	}
+
+	order, err := keyOrder(v)
+	if err != nil {
+		return err
+	}
+
+	result, err := queryJSON(v, opts)
+	if err != nil {
+		return err
+	}
+
+	obj, ok := result.(map[string]interface{})
+	if !ok {
+		_, err := fmt.Fprintln(w, formatValue(result))
+
+		return err
+	}
+
+	for _, k := range sortedKeys(obj, order) {
+		if _, err := fmt.Fprintf(w, "%-20s%v\n", k+":", formatValue(obj[k])); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// keyOrder returns the position of every object key of the JSON encoding
+// of v, in the order the keys are first encoded, which for structs is the
+// order of their fields
+func keyOrder(v interface{}) (map[string]int, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	type frame struct {
+		object bool
+		key    bool
+	}
+
+	var (
+		order = make(map[string]int)
+		stack []frame
+	)
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			return order, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		top := len(stack) - 1
+
+		if key, ok := tok.(string); ok && top >= 0 && stack[top].key {
+			if _, ok := order[key]; !ok {
+				order[key] = len(order)
+			}
+
+			stack[top].key = false
+
+			continue
+		}
+
+		switch tok {
+		case json.Delim('{'):
+			stack = append(stack, frame{object: true, key: true})
+
+			continue
+		case json.Delim('['):
+			stack = append(stack, frame{})
+
+			continue
+		case json.Delim('}'), json.Delim(']'):
+			stack = stack[:top]
+		}
+
+		// a value ended, so the enclosing object continues with a key
+		if top = len(stack) - 1; top >= 0 && stack[top].object {
+			stack[top].key = true
+		}
+	}
+}
+
+// sortedKeys returns the keys of obj by their position in order, followed
+// by the keys missing from order in alphabetical order
+func sortedKeys(obj map[string]interface{}, order map[string]int) []string {
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		x, okX := order[keys[i]]
+		y, okY := order[keys[j]]
+
+		switch {
+		case okX && okY:
+			return x < y
+		case okX != okY:
+			return okX
+		default:
+			return keys[i] < keys[j]
+		}
+	})
+
+	return keys
+}
+
+func formatValue(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(value)
+	default:
+		data, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Sprintf("%v", value)
+		}
+
+		return string(data)
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2023 Edson Michaque
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package formatter
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testAccount struct {
+	Name  string   `json:"name"`
+	ID    int      `json:"id"`
+	Plan  string   `json:"plan"`
+	Roles []string `json:"roles"`
+}
+
+var testAccounts = []testAccount{
+	{Name: "first", ID: 1, Plan: "free", Roles: []string{"owner"}},
+	{Name: "second", ID: 2, Plan: "pro"},
+}
+
+func TestValidateQuery(t *testing.T) {
+	require.NoError(t, ValidateQuery(""))
+	require.NoError(t, ValidateQuery("[].{id:id}"))
+	require.Error(t, ValidateQuery("[].{id"))
+}
+
+func TestQueryTable(t *testing.T) {
+	type testcase struct {
+		query  string
+		header []string
+		rows   []map[string]string
+	}
+
+	tt := map[string]testcase{
+		"projection keeps the field order": {
+			query:  "[].{plan:plan,id:id,name:name}",
+			header: []string{"NAME", "ID", "PLAN"},
+			rows: []map[string]string{
+				{"NAME": "first", "ID": "1", "PLAN": "free"},
+				{"NAME": "second", "ID": "2", "PLAN": "pro"},
+			},
+		},
+		"new keys follow the fields": {
+			query:  "[].{b:plan,id:id,a:name}",
+			header: []string{"ID", "A", "B"},
+			rows: []map[string]string{
+				{"ID": "1", "A": "first", "B": "free"},
+				{"ID": "2", "A": "second", "B": "pro"},
+			},
+		},
+		"single object": {
+			query:  "[0]",
+			header: []string{"NAME", "ID", "PLAN", "ROLES"},
+			rows: []map[string]string{
+				{"NAME": "first", "ID": "1", "PLAN": "free", "ROLES": `["owner"]`},
+			},
+		},
+		"scalars": {
+			query:  "[].name",
+			header: []string{queryValueColumn},
+			rows: []map[string]string{
+				{queryValueColumn: "first"},
+				{queryValueColumn: "second"},
+			},
+		},
+		"null": {
+			query:  "[1].roles",
+			header: []string{queryValueColumn},
+			rows: []map[string]string{
+				{queryValueColumn: ""},
+			},
+		},
+	}
+
+	for tn, tc := range tt {
+		t.Run(tn, func(t *testing.T) {
+			table, err := queryTable(testAccounts, &Opts{Query: tc.query})
+			require.NoError(t, err)
+			require.Equal(t, tc.header, table.header)
+			require.Equal(t, tc.rows, table.rows)
+		})
+	}
+}
+
+func TestQueryTableIterator(t *testing.T) {
+	i := 0
+
+	it := NewIterator(func() (interface{}, error) {
+		if i == len(testAccounts) {
+			return nil, io.EOF
+		}
+
+		i++
+
+		return testAccounts[i-1], nil
+	})
+
+	table, err := queryTable(it, &Opts{Query: "[].{id:id,name:name}"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"NAME", "ID"}, table.header)
+	require.Len(t, table.rows, 2)
+}
+
+func TestFormatQueryText(t *testing.T) {
+	var buf bytes.Buffer
+
+	require.NoError(t, formatQueryText(&buf, testAccounts[0], &Opts{Query: "{plan:plan,name:name}"}))
+	require.Equal(t, "name:               first\nplan:               free\n", buf.String())
+
+	buf.Reset()
+
+	require.NoError(t, formatQueryText(&buf, testAccounts, &Opts{Query: "length(@)"}))
+	require.Equal(t, "2\n", buf.String())
+}
@@ -0,0 +1,44 @@
+// Copyright 2023 Edson Michaque
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package formatter
+
+// Redactor is implemented by values holding secrets. Redact returns a
+// copy of the value with the secrets masked.
+type Redactor interface {
+	Redact() interface{}
+}
+
+func redact(data interface{}) interface{} {
+	if r, ok := data.(Redactor); ok {
+		return r.Redact()
+	}
+
+	if it, ok := data.(Iterator); ok {
+		return redactIterator{Iterator: it}
+	}
+
+	return data
+}
+
+// redactIterator redacts the items of an Iterator as they are yielded
+type redactIterator struct {
+	Iterator
+}
+
+func (r redactIterator) Item() interface{} {
+	return redact(r.Iterator.Item())
+}
@@ -0,0 +1,349 @@
+// Copyright 2023 Edson Michaque
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package formatter
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const (
+	tagTable    = "table"
+	tagText     = "text"
+	tagOptWide  = "wide"
+	tagSkip     = "-"
+	tableJoiner = "_"
+	textJoiner  = " "
+)
+
+var (
+	typeTime     = reflect.TypeOf(time.Time{})
+	typeStringer = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+)
+
+// column describes a field tagged with `table:"TITLE[,wide]"` and
+// `text:"Title"`. When one of the tags is missing, the other is used as
+// title so table and text output stay consistent.
+type column struct {
+	title string
+	text  string
+	wide  bool
+	index []int
+}
+
+// columnsOf derives the columns of a struct type, flattening nested
+// structs so that their columns are prefixed by the parent title
+func columnsOf(t reflect.Type) []column {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	cols := make([]column, 0, t.NumField())
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		tableTag, hasTable := field.Tag.Lookup(tagTable)
+		textTag, hasText := field.Tag.Lookup(tagText)
+
+		if tableTag == tagSkip || textTag == tagSkip {
+			continue
+		}
+
+		title, opts, _ := strings.Cut(tableTag, ",")
+
+		col := column{
+			title: title,
+			text:  textTag,
+			wide:  opts == tagOptWide,
+			index: field.Index,
+		}
+
+		if col.title == "" {
+			col.title = strings.ToUpper(strings.ReplaceAll(col.text, textJoiner, tableJoiner))
+		}
+
+		if col.text == "" {
+			col.text = col.title
+		}
+
+		if isNested(field.Type) {
+			for _, child := range columnsOf(field.Type) {
+				child.index = append(append([]int{}, field.Index...), child.index...)
+				child.wide = child.wide || col.wide
+
+				if col.title != "" {
+					child.title = col.title + tableJoiner + child.title
+					child.text = col.text + textJoiner + child.text
+				}
+
+				cols = append(cols, child)
+			}
+
+			continue
+		}
+
+		if !hasTable && !hasText {
+			continue
+		}
+
+		cols = append(cols, col)
+	}
+
+	return cols
+}
+
+func isNested(t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct || t == typeTime {
+		return false
+	}
+
+	return !t.Implements(typeStringer) && !reflect.PointerTo(t).Implements(typeStringer)
+}
+
+// fieldValue walks index from v, returning false on a nil pointer
+func fieldValue(v reflect.Value, index []int) (reflect.Value, bool) {
+	for _, i := range index {
+		for v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+
+			v = v.Elem()
+		}
+
+		v = v.Field(i)
+	}
+
+	return v, true
+}
+
+func cellValue(v reflect.Value) string {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return ""
+		}
+
+		// keep pointers whose String method has a pointer receiver, but
+		// not pointers to time values, which are formatted below
+		if v.Kind() == reflect.Pointer && v.Type().Elem() != typeTime && v.Type().Implements(typeStringer) {
+			break
+		}
+
+		v = v.Elem()
+	}
+
+	if v.Type() == typeTime {
+		t := v.Interface().(time.Time)
+		if t.IsZero() {
+			return ""
+		}
+
+		return t.Format(time.RFC3339)
+	}
+
+	if s, ok := v.Interface().(fmt.Stringer); ok {
+		return s.String()
+	}
+
+	return fmt.Sprintf("%v", v.Interface())
+}
+
+// items returns the elements of a slice, or the value itself
+func items(data interface{}) (reflect.Type, []reflect.Value) {
+	v := reflect.ValueOf(data)
+	if !v.IsValid() {
+		return nil, nil
+	}
+
+	for v.Kind() == reflect.Pointer && !v.IsNil() {
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return v.Type(), []reflect.Value{v}
+	}
+
+	values := make([]reflect.Value, 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		values = append(values, v.Index(i))
+	}
+
+	return v.Type().Elem(), values
+}
+
+// itemColumns returns the columns of t, hiding wide columns unless wide
+func itemColumns(t reflect.Type, wide bool) ([]column, error) {
+	cols := columnsOf(t)
+	if len(cols) == 0 {
+		return nil, errors.New("table formatter is not implemented")
+	}
+
+	if wide {
+		return cols, nil
+	}
+
+	visible := make([]column, 0, len(cols))
+	for _, col := range cols {
+		if !col.wide {
+			visible = append(visible, col)
+		}
+	}
+
+	return visible, nil
+}
+
+// eachRow calls header once with the column titles of data, which must
+// be a struct, a slice of structs or an Iterator of structs, and then
+// row with the cells of every item. Columns tagged as wide are only
+// included when wide is set.
+func eachRow(
+	data interface{},
+	wide bool,
+	header func([]string) error,
+	row func(map[string]string) error,
+) error {
+	var cols []column
+
+	setColumns := func(t reflect.Type) error {
+		var err error
+
+		cols, err = itemColumns(t, wide)
+		if err != nil {
+			return err
+		}
+
+		titles := make([]string, 0, len(cols))
+		for _, col := range cols {
+			titles = append(titles, col.title)
+		}
+
+		return header(titles)
+	}
+
+	if _, ok := data.(Iterator); !ok {
+		t, _ := items(data)
+		if t == nil {
+			return errors.New("table formatter is not implemented")
+		}
+
+		if err := setColumns(t); err != nil {
+			return err
+		}
+	}
+
+	return each(data, func(item interface{}) error {
+		v := reflect.ValueOf(item)
+
+		if cols == nil {
+			if err := setColumns(v.Type()); err != nil {
+				return err
+			}
+		}
+
+		cells := make(map[string]string, len(cols))
+
+		for _, col := range cols {
+			if field, ok := fieldValue(v, col.index); ok {
+				cells[col.title] = cellValue(field)
+			}
+		}
+
+		return row(cells)
+	})
+}
+
+// structTable builds table data from the tagged fields of data
+func structTable(data interface{}, wide bool) (tableData, error) {
+	var table tableData
+
+	err := eachRow(
+		data,
+		wide,
+		func(header []string) error {
+			table.header = header
+
+			return nil
+		},
+		func(row map[string]string) error {
+			table.rows = append(table.rows, row)
+
+			return nil
+		},
+	)
+	if err != nil {
+		return tableData{}, err
+	}
+
+	return table, nil
+}
+
+// structText writes the tagged fields of data as "Title: value" lines,
+// separating the items of a list by a blank line
+func structText(w io.Writer, data interface{}) error {
+	var (
+		cols  []column
+		count int
+	)
+
+	return each(data, func(item interface{}) error {
+		v := reflect.ValueOf(item)
+
+		if cols == nil {
+			cols = columnsOf(v.Type())
+			if len(cols) == 0 {
+				return errors.New("text formatter is not implemented")
+			}
+		}
+
+		if count > 0 {
+			if _, err := io.WriteString(w, "\n"); err != nil {
+				return err
+			}
+		}
+
+		count++
+
+		for _, col := range cols {
+			var cell string
+			if field, ok := fieldValue(v, col.index); ok {
+				cell = cellValue(field)
+			}
+
+			if _, err := fmt.Fprintf(w, "%-20s%v\n", col.text+":", cell); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
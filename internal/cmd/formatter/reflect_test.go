@@ -0,0 +1,228 @@
+// Copyright 2023 Edson Michaque
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package formatter
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testOwner struct {
+	Name  string `table:"NAME" text:"Name"`
+	Email string `table:"EMAIL,wide" text:"Email"`
+}
+
+type testItem struct {
+	ID        int        `table:"ID" text:"ID"`
+	Owner     testOwner  `table:"OWNER" text:"Owner"`
+	Parent    *testOwner `table:"PARENT" text:"Parent"`
+	CreatedAt time.Time  `table:"CREATED_AT" text:"Created at"`
+	UpdatedAt *time.Time `table:"UPDATED_AT,wide" text:"Updated at"`
+	Skipped   string     `table:"-"`
+	Untagged  string
+	internal  string
+}
+
+func TestColumnsOf(t *testing.T) {
+	type testcase struct {
+		typ   reflect.Type
+		title []string
+		text  []string
+		wide  []bool
+	}
+
+	tt := map[string]testcase{
+		"nested struct and pointer": {
+			typ: reflect.TypeOf(testItem{}),
+			title: []string{
+				"ID", "OWNER_NAME", "OWNER_EMAIL", "PARENT_NAME", "PARENT_EMAIL", "CREATED_AT", "UPDATED_AT",
+			},
+			text: []string{
+				"ID", "Owner Name", "Owner Email", "Parent Name", "Parent Email", "Created at", "Updated at",
+			},
+			wide: []bool{false, false, true, false, true, false, true},
+		},
+		"pointer to struct": {
+			typ:   reflect.TypeOf(&testOwner{}),
+			title: []string{"NAME", "EMAIL"},
+			text:  []string{"Name", "Email"},
+			wide:  []bool{false, true},
+		},
+		"not a struct": {
+			typ: reflect.TypeOf(""),
+		},
+	}
+
+	for tn, tc := range tt {
+		t.Run(tn, func(t *testing.T) {
+			var title, text []string
+
+			var wide []bool
+
+			for _, col := range columnsOf(tc.typ) {
+				title = append(title, col.title)
+				text = append(text, col.text)
+				wide = append(wide, col.wide)
+			}
+
+			require.Equal(t, tc.title, title)
+			require.Equal(t, tc.text, text)
+			require.Equal(t, tc.wide, wide)
+		})
+	}
+}
+
+func TestFieldValue(t *testing.T) {
+	cols := columnsOf(reflect.TypeOf(testItem{}))
+
+	index := func(title string) []int {
+		for _, col := range cols {
+			if col.title == title {
+				return col.index
+			}
+		}
+
+		t.Fatalf("no column %s", title)
+
+		return nil
+	}
+
+	type testcase struct {
+		item  interface{}
+		title string
+		want  interface{}
+		ok    bool
+	}
+
+	tt := map[string]testcase{
+		"nested field": {
+			item:  testItem{Owner: testOwner{Name: "owner"}},
+			title: "OWNER_NAME",
+			want:  "owner",
+			ok:    true,
+		},
+		"field of a pointer": {
+			item:  testItem{Parent: &testOwner{Email: "parent@example.com"}},
+			title: "PARENT_EMAIL",
+			want:  "parent@example.com",
+			ok:    true,
+		},
+		"field of a nil pointer": {
+			item:  testItem{},
+			title: "PARENT_NAME",
+		},
+		"pointer to the item": {
+			item:  &testItem{ID: 1},
+			title: "ID",
+			want:  1,
+			ok:    true,
+		},
+	}
+
+	for tn, tc := range tt {
+		t.Run(tn, func(t *testing.T) {
+			v, ok := fieldValue(reflect.ValueOf(tc.item), index(tc.title))
+			require.Equal(t, tc.ok, ok)
+
+			if tc.ok {
+				require.Equal(t, tc.want, v.Interface())
+			}
+		})
+	}
+}
+
+func TestCellValue(t *testing.T) {
+	date := time.Date(2023, 3, 14, 15, 9, 26, 0, time.UTC)
+
+	var nilTime *time.Time
+
+	type testcase struct {
+		value interface{}
+		want  string
+	}
+
+	tt := map[string]testcase{
+		"int":             {value: 42, want: "42"},
+		"string":          {value: "name", want: "name"},
+		"time":            {value: date, want: "2023-03-14T15:09:26Z"},
+		"zero time":       {value: time.Time{}, want: ""},
+		"pointer to time": {value: &date, want: "2023-03-14T15:09:26Z"},
+		"nil pointer":     {value: nilTime, want: ""},
+		"stringer":        {value: time.Second, want: "1s"},
+	}
+
+	for tn, tc := range tt {
+		t.Run(tn, func(t *testing.T) {
+			require.Equal(t, tc.want, cellValue(reflect.ValueOf(tc.value)))
+		})
+	}
+}
+
+func TestStructTableWide(t *testing.T) {
+	date := time.Date(2023, 3, 14, 15, 9, 26, 0, time.UTC)
+
+	items := []testItem{
+		{
+			ID:        1,
+			Owner:     testOwner{Name: "owner", Email: "owner@example.com"},
+			CreatedAt: date,
+			UpdatedAt: &date,
+		},
+	}
+
+	type testcase struct {
+		wide   bool
+		header []string
+		row    map[string]string
+	}
+
+	tt := map[string]testcase{
+		"narrow": {
+			header: []string{"ID", "OWNER_NAME", "PARENT_NAME", "CREATED_AT"},
+			row: map[string]string{
+				"ID":         "1",
+				"OWNER_NAME": "owner",
+				"CREATED_AT": "2023-03-14T15:09:26Z",
+			},
+		},
+		"wide": {
+			wide: true,
+			header: []string{
+				"ID", "OWNER_NAME", "OWNER_EMAIL", "PARENT_NAME", "PARENT_EMAIL", "CREATED_AT", "UPDATED_AT",
+			},
+			row: map[string]string{
+				"ID":          "1",
+				"OWNER_NAME":  "owner",
+				"OWNER_EMAIL": "owner@example.com",
+				"CREATED_AT":  "2023-03-14T15:09:26Z",
+				"UPDATED_AT":  "2023-03-14T15:09:26Z",
+			},
+		},
+	}
+
+	for tn, tc := range tt {
+		t.Run(tn, func(t *testing.T) {
+			table, err := structTable(items, tc.wide)
+			require.NoError(t, err)
+			require.Equal(t, tc.header, table.header)
+			require.Equal(t, []map[string]string{tc.row}, table.rows)
+		})
+	}
+}
@@ -0,0 +1,242 @@
+// Copyright 2023 Edson Michaque
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package formatter
+
+import (
+	"io"
+	"strings"
+)
+
+// Formatter describes an output format: Supports reports whether a value
+// can be written by Format
+type Formatter struct {
+	Supports func(data interface{}) bool
+	Format   func(w io.Writer, data interface{}, opts *Opts) error
+}
+
+type registryEntry struct {
+	name      Output
+	formatter Formatter
+}
+
+var tableOutput = Formatter{
+	Supports: supportsTable,
+	Format: func(w io.Writer, data interface{}, opts *Opts) error {
+		if f, ok := data.(TableFormatter); ok {
+			return f.FormatTable(w, opts)
+		}
+
+		return formatTable(w, data, opts)
+	},
+}
+
+var registry = []registryEntry{
+	{
+		name:      OutputTable,
+		formatter: tableOutput,
+	},
+	{
+		name:      OutputWide,
+		formatter: tableOutput,
+	},
+	{
+		name: OutputText,
+		formatter: Formatter{
+			Supports: func(data interface{}) bool {
+				_, ok := data.(TextFormatter)
+
+				return ok || isIterator(data)
+			},
+			Format: func(w io.Writer, data interface{}, opts *Opts) error {
+				if f, ok := data.(TextFormatter); ok {
+					return f.FormatText(w, opts)
+				}
+
+				return formatText(w, data, opts)
+			},
+		},
+	},
+	{
+		name: OutputJSON,
+		formatter: Formatter{
+			Supports: supportsJSON,
+			Format: func(w io.Writer, data interface{}, opts *Opts) error {
+				if f, ok := data.(JSONFormatter); ok {
+					return f.FormatJSON(w, opts)
+				}
+
+				return formatJSON(w, data, opts)
+			},
+		},
+	},
+	{
+		name: OutputJSONL,
+		formatter: Formatter{
+			Supports: func(data interface{}) bool {
+				_, ok := data.(JSONLFormatter)
+
+				return ok || supportsJSON(data)
+			},
+			Format: func(w io.Writer, data interface{}, opts *Opts) error {
+				if f, ok := data.(JSONLFormatter); ok {
+					return f.FormatJSONL(w, opts)
+				}
+
+				return formatJSONL(w, data, opts)
+			},
+		},
+	},
+	{
+		name: OutputYAML,
+		formatter: Formatter{
+			Supports: func(data interface{}) bool {
+				_, ok := data.(YAMLFormatter)
+
+				return ok || isIterator(data)
+			},
+			Format: func(w io.Writer, data interface{}, opts *Opts) error {
+				if f, ok := data.(YAMLFormatter); ok {
+					return f.FormatYAML(w, opts)
+				}
+
+				return formatYAML(w, data, opts)
+			},
+		},
+	},
+	{
+		name: OutputCSV,
+		formatter: Formatter{
+			Supports: func(data interface{}) bool {
+				_, ok := data.(CSVFormatter)
+
+				return ok || supportsTable(data)
+			},
+			Format: func(w io.Writer, data interface{}, opts *Opts) error {
+				if f, ok := data.(CSVFormatter); ok {
+					return f.FormatCSV(w, opts)
+				}
+
+				return formatCSV(w, data, opts, ',')
+			},
+		},
+	},
+	{
+		name: OutputTSV,
+		formatter: Formatter{
+			Supports: func(data interface{}) bool {
+				_, ok := data.(TSVFormatter)
+
+				return ok || supportsTable(data)
+			},
+			Format: func(w io.Writer, data interface{}, opts *Opts) error {
+				if f, ok := data.(TSVFormatter); ok {
+					return f.FormatTSV(w, opts)
+				}
+
+				return formatCSV(w, data, opts, '\t')
+			},
+		},
+	},
+	{
+		name: OutputTemplate,
+		formatter: Formatter{
+			Supports: func(data interface{}) bool {
+				_, ok := data.(TemplateFormatter)
+
+				return ok || supportsJSON(data)
+			},
+			Format: func(w io.Writer, data interface{}, opts *Opts) error {
+				if f, ok := data.(TemplateFormatter); ok {
+					return f.FormatTemplate(w, opts)
+				}
+
+				return formatTemplate(w, data, opts)
+			},
+		},
+	},
+}
+
+// Register makes an output available under name, replacing the output
+// previously registered with the same name
+func Register(name Output, f Formatter) {
+	for i := range registry {
+		if registry[i].name == name {
+			registry[i].formatter = f
+
+			return
+		}
+	}
+
+	registry = append(registry, registryEntry{name: name, formatter: f})
+}
+
+// Formats returns the outputs supporting data, in registration order
+func Formats(data interface{}) []Output {
+	outputs := make([]Output, 0, len(registry))
+
+	for _, entry := range registry {
+		if entry.formatter.Supports(data) {
+			outputs = append(outputs, entry.name)
+		}
+	}
+
+	return outputs
+}
+
+// Supports reports whether output is registered and supports data
+func Supports(data interface{}, output Output) bool {
+	f, ok := lookup(output)
+
+	return ok && f.Supports(data)
+}
+
+func lookup(name Output) (Formatter, bool) {
+	for _, entry := range registry {
+		if entry.name == name {
+			return entry.formatter, true
+		}
+	}
+
+	return Formatter{}, false
+}
+
+func joinOutputs(outputs []Output) string {
+	names := make([]string, 0, len(outputs))
+	for _, output := range outputs {
+		names = append(names, string(output))
+	}
+
+	return strings.Join(names, ", ")
+}
+
+func isIterator(data interface{}) bool {
+	_, ok := data.(Iterator)
+
+	return ok
+}
+
+func supportsJSON(data interface{}) bool {
+	_, ok := data.(JSONFormatter)
+
+	return ok || isIterator(data)
+}
+
+func supportsTable(data interface{}) bool {
+	_, ok := data.(TableFormatter)
+
+	return ok || isIterator(data)
+}
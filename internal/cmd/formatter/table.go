@@ -0,0 +1,209 @@
+// Copyright 2023 Edson Michaque
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package formatter
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	tablePadding   = 2
+	minColumnWidth = 6
+	ellipsis       = "..."
+)
+
+type tableData struct {
+	header []string
+	rows   []map[string]string
+}
+
+// prepareTable derives the table of v and applies the query, column
+// selection and sorting options
+func prepareTable(v interface{}, opts *Opts) (tableData, error) {
+	var (
+		t   tableData
+		err error
+	)
+
+	if opts.Query != "" {
+		t, err = queryTable(v, opts)
+	} else {
+		t, err = structTable(v, opts.Output != OutputTable || len(opts.Columns) > 0)
+	}
+
+	if err != nil {
+		return tableData{}, err
+	}
+
+	header, err := selectColumns(t.header, opts.Columns)
+	if err != nil {
+		return tableData{}, err
+	}
+
+	data := tableData{
+		header: header,
+		rows:   t.rows,
+	}
+
+	if opts.SortBy != "" {
+		col, err := findColumn(t.header, opts.SortBy)
+		if err != nil {
+			return tableData{}, err
+		}
+
+		sort.SliceStable(data.rows, func(i, j int) bool {
+			return lessValue(data.rows[i][col], data.rows[j][col])
+		})
+	}
+
+	if opts.Reverse {
+		for i, j := 0, len(data.rows)-1; i < j; i, j = i+1, j-1 {
+			data.rows[i], data.rows[j] = data.rows[j], data.rows[i]
+		}
+	}
+
+	return data, nil
+}
+
+// selectColumns returns the columns of header named by names, in order,
+// or header itself when names is empty
+func selectColumns(header, names []string) ([]string, error) {
+	if len(names) == 0 {
+		return header, nil
+	}
+
+	cols := make([]string, 0, len(names))
+
+	for _, name := range names {
+		col, err := findColumn(header, name)
+		if err != nil {
+			return nil, err
+		}
+
+		cols = append(cols, col)
+	}
+
+	return cols, nil
+}
+
+// ValidateColumns reports whether names are columns of the table output of
+// data, wide columns included. Only columns derived from struct tags are
+// known before formatting, so other data is not checked.
+func ValidateColumns(data interface{}, names ...string) error {
+	t, _ := items(data)
+	if t == nil {
+		return nil
+	}
+
+	cols := columnsOf(t)
+	if len(cols) == 0 {
+		return nil
+	}
+
+	header := make([]string, 0, len(cols))
+	for _, col := range cols {
+		header = append(header, col.title)
+	}
+
+	_, err := selectColumns(header, names)
+
+	return err
+}
+
+func findColumn(header []string, name string) (string, error) {
+	for _, col := range header {
+		if strings.EqualFold(col, strings.TrimSpace(name)) {
+			return col, nil
+		}
+	}
+
+	return "", fmt.Errorf(
+		"unknown column %q, expected one of: %s",
+		name,
+		strings.ToLower(strings.Join(header, ", ")),
+	)
+}
+
+func lessValue(a, b string) bool {
+	x, errX := strconv.ParseFloat(a, 64)
+	y, errY := strconv.ParseFloat(b, 64)
+
+	if errX == nil && errY == nil {
+		return x < y
+	}
+
+	return a < b
+}
+
+// fitTable returns the width of every column of t, shrinking the widest
+// columns until the table fits in width. Columns are not shrunk below
+// minColumnWidth, so very narrow terminals may still wrap.
+func fitTable(t tableData, width int, noHeaders bool) []int {
+	widths := make([]int, len(t.header))
+	total := tablePadding * (len(t.header) - 1)
+
+	for i, col := range t.header {
+		if !noHeaders {
+			widths[i] = utf8.RuneCountInString(col)
+		}
+
+		for _, row := range t.rows {
+			if n := utf8.RuneCountInString(row[col]); n > widths[i] {
+				widths[i] = n
+			}
+		}
+
+		total += widths[i]
+	}
+
+	for total > width {
+		widest := 0
+		for i := range widths {
+			if widths[i] > widths[widest] {
+				widest = i
+			}
+		}
+
+		if widths[widest] <= minColumnWidth {
+			break
+		}
+
+		widths[widest]--
+		total--
+	}
+
+	return widths
+}
+
+// truncate ellipsizes s when it is longer than length
+func truncate(s string, length int) string {
+	if utf8.RuneCountInString(s) <= length {
+		return s
+	}
+
+	runes := []rune(s)
+
+	if length <= len(ellipsis) {
+		return string(runes[:length])
+	}
+
+	return string(runes[:length-len(ellipsis)]) + ellipsis
+}
@@ -0,0 +1,236 @@
+// Copyright 2023 Edson Michaque
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package formatter
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testRow struct {
+	ID    int    `table:"ID"`
+	Name  string `table:"NAME"`
+	Email string `table:"EMAIL,wide"`
+}
+
+var testRows = []testRow{
+	{ID: 10, Name: "second", Email: "second@example.com"},
+	{ID: 9, Name: "first", Email: "first@example.com"},
+}
+
+func TestPrepareTable(t *testing.T) {
+	type testcase struct {
+		opts   Opts
+		header []string
+		names  []string
+		err    string
+	}
+
+	tt := map[string]testcase{
+		"hides wide columns": {
+			opts:   Opts{Output: OutputTable},
+			header: []string{"ID", "NAME"},
+			names:  []string{"second", "first"},
+		},
+		"wide output": {
+			opts:   Opts{Output: OutputWide},
+			header: []string{"ID", "NAME", "EMAIL"},
+			names:  []string{"second", "first"},
+		},
+		"selected columns include wide ones": {
+			opts:   Opts{Output: OutputTable, Columns: []string{"email", "Id"}},
+			header: []string{"EMAIL", "ID"},
+			names:  []string{"second", "first"},
+		},
+		"sorts numbers by value": {
+			opts:   Opts{Output: OutputTable, SortBy: "id"},
+			header: []string{"ID", "NAME"},
+			names:  []string{"first", "second"},
+		},
+		"reverse": {
+			opts:   Opts{Output: OutputTable, SortBy: "name", Reverse: true},
+			header: []string{"ID", "NAME"},
+			names:  []string{"second", "first"},
+		},
+		"unknown column": {
+			opts: Opts{Output: OutputTable, Columns: []string{"age"}},
+			err:  `unknown column "age", expected one of: id, name, email`,
+		},
+	}
+
+	for tn, tc := range tt {
+		t.Run(tn, func(t *testing.T) {
+			rows := append([]testRow{}, testRows...)
+
+			table, err := prepareTable(rows, &tc.opts)
+			if tc.err != "" {
+				require.EqualError(t, err, tc.err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.header, table.header)
+
+			var names []string
+			for _, row := range table.rows {
+				names = append(names, row["NAME"])
+			}
+
+			require.Equal(t, tc.names, names)
+		})
+	}
+}
+
+func TestFitTable(t *testing.T) {
+	type testcase struct {
+		header    []string
+		rows      []map[string]string
+		width     int
+		noHeaders bool
+		want      []int
+	}
+
+	tt := map[string]testcase{
+		"fits": {
+			header: []string{"ID", "NAME"},
+			rows:   []map[string]string{{"ID": "1", "NAME": "first"}},
+			width:  80,
+			want:   []int{2, 5},
+		},
+		"shrinks the widest column": {
+			header: []string{"ID", "DESCRIPTION"},
+			rows:   []map[string]string{{"ID": "12345678", "DESCRIPTION": "a rather long description"}},
+			width:  30,
+			want:   []int{8, 20},
+		},
+		"shrinks wide headers": {
+			header: []string{"ID", "A_VERY_LONG_HEADER"},
+			rows:   []map[string]string{{"ID": "1", "A_VERY_LONG_HEADER": "x"}},
+			width:  14,
+			want:   []int{2, 10},
+		},
+		"ignores headers that are not shown": {
+			header:    []string{"ID", "A_VERY_LONG_HEADER"},
+			rows:      []map[string]string{{"ID": "1", "A_VERY_LONG_HEADER": "x"}},
+			width:     80,
+			noHeaders: true,
+			want:      []int{1, 1},
+		},
+		"keeps a minimum width": {
+			header: []string{"NAME", "EMAIL"},
+			rows:   []map[string]string{{"NAME": "first", "EMAIL": "first@example.com"}},
+			width:  5,
+			want:   []int{5, minColumnWidth},
+		},
+	}
+
+	for tn, tc := range tt {
+		t.Run(tn, func(t *testing.T) {
+			got := fitTable(tableData{header: tc.header, rows: tc.rows}, tc.width, tc.noHeaders)
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	type testcase struct {
+		value  string
+		length int
+		want   string
+	}
+
+	tt := map[string]testcase{
+		"fits": {
+			value:  "first",
+			length: 5,
+			want:   "first",
+		},
+		"ellipsis": {
+			value:  "description",
+			length: 8,
+			want:   "descr...",
+		},
+		"counts runes": {
+			value:  "déjà vu encore",
+			length: 7,
+			want:   "déjà...",
+		},
+		"shorter than the ellipsis": {
+			value:  "description",
+			length: 2,
+			want:   "de",
+		},
+	}
+
+	for tn, tc := range tt {
+		t.Run(tn, func(t *testing.T) {
+			require.Equal(t, tc.want, truncate(tc.value, tc.length))
+		})
+	}
+}
+
+func TestFormatTable(t *testing.T) {
+	type testcase struct {
+		data interface{}
+		opts Opts
+		want string
+	}
+
+	tt := map[string]testcase{
+		"table": {
+			opts: Opts{Output: OutputTable},
+			want: "ID  NAME\n10  second\n9   first\n",
+		},
+		"wide": {
+			opts: Opts{Output: OutputWide},
+			want: "ID  NAME    EMAIL\n10  second  second@example.com\n9   first   first@example.com\n",
+		},
+		"no headers": {
+			opts: Opts{Output: OutputTable, NoHeaders: true},
+			want: "10  second\n9   first\n",
+		},
+		"fits the width": {
+			opts: Opts{Output: OutputWide, Width: 26},
+			want: "ID  NAME    EMAIL\n10  second  second@exam...\n9   first   first@examp...\n",
+		},
+		"truncates headers": {
+			data: []struct {
+				ID   int    `table:"ID"`
+				Note string `table:"A_VERY_LONG_HEADER"`
+			}{{ID: 1, Note: "x"}},
+			opts: Opts{Output: OutputTable, Width: 14},
+			want: "ID  A_VERY_...\n1   x\n",
+		},
+	}
+
+	for tn, tc := range tt {
+		t.Run(tn, func(t *testing.T) {
+			var buf bytes.Buffer
+
+			data := tc.data
+			if data == nil {
+				data = append([]testRow{}, testRows...)
+			}
+
+			require.NoError(t, formatTable(&buf, data, &tc.opts))
+			require.Equal(t, tc.want, buf.String())
+		})
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright 2023 Edson Michaque
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/edsonmichaque/template-cli/internal/cmd/formatter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"golang.org/x/term"
+)
+
+// formatOpts builds formatter options from output flags. Named views are
+// looked up under views.<view>.<name> in the configuration.
+func formatOpts(cmd *cobra.Command, view string) (*formatter.Opts, error) {
+	opts := &formatter.Opts{
+		Width:     terminalWidth(cmd.OutOrStdout()),
+		Output:    formatter.Output(viper.GetString(optOutput)),
+		NoHeaders: viper.GetBool(optNoHeaders),
+		Query:     viper.GetString(optQuery),
+		Columns:   viper.GetStringSlice(optColumns),
+		SortBy:    viper.GetString(optSortBy),
+		Reverse:   viper.GetBool(optReverse),
+
+		ShowSecrets: viper.GetBool(optShowSecrets),
+	}
+
+	if name := strings.TrimPrefix(string(opts.Output), outputViewPrefix); name != string(opts.Output) {
+		key := strings.Join([]string{optViews, view, name}, ".")
+
+		tpl := viper.GetString(key)
+		if tpl == "" {
+			return nil, fmt.Errorf(`view "%s" not found in "%s"`, name, key)
+		}
+
+		opts.Output = formatter.OutputTemplate
+		opts.Template = tpl
+
+		return opts, nil
+	}
+
+	if opts.Output != formatter.OutputTemplate {
+		return opts, nil
+	}
+
+	if path := viper.GetString(optTemplateFile); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		opts.Template = string(data)
+	} else {
+		opts.Template = viper.GetString(optTemplate)
+	}
+
+	if opts.Template == "" {
+		return nil, fmt.Errorf(`flag "%s" or "%s" is required by output "%s"`, optTemplate, optTemplateFile, outputTemplate)
+	}
+
+	return opts, nil
+}
+
+// flagOutput validates the output flag against the outputs supported by
+// data. Named views are rendered by the template output.
+func flagOutput(data interface{}) error {
+	output := formatter.Output(viper.GetString(optOutput))
+	if strings.HasPrefix(string(output), outputViewPrefix) {
+		output = formatter.OutputTemplate
+	}
+
+	if formatter.Supports(data, output) {
+		return nil
+	}
+
+	return fmt.Errorf(
+		`flag "%s" has invalid value "%s", expected one of: %s`,
+		optOutput,
+		viper.GetString(optOutput),
+		strings.Join(outputNames(data), ", "),
+	)
+}
+
+// outputNames returns the names of the outputs supported by data
+func outputNames(data interface{}) []string {
+	outputs := formatter.Formats(data)
+
+	names := make([]string, 0, len(outputs))
+	for _, output := range outputs {
+		names = append(names, string(output))
+	}
+
+	return names
+}
+
+// flagQuery validates the query flag
+func flagQuery() error {
+	if err := formatter.ValidateQuery(viper.GetString(optQuery)); err != nil {
+		return fmt.Errorf(`flag "%s" has invalid value: %w`, optQuery, err)
+	}
+
+	return nil
+}
+
+// flagColumns validates the columns and sort-by flags against the columns
+// of data. The columns of a query are only known once it is evaluated.
+func flagColumns(data interface{}) error {
+	if viper.GetString(optQuery) != "" {
+		return nil
+	}
+
+	if err := formatter.ValidateColumns(data, viper.GetStringSlice(optColumns)...); err != nil {
+		return fmt.Errorf(`flag "%s" has invalid value: %w`, optColumns, err)
+	}
+
+	if sortBy := viper.GetString(optSortBy); sortBy != "" {
+		if err := formatter.ValidateColumns(data, sortBy); err != nil {
+			return fmt.Errorf(`flag "%s" has invalid value: %w`, optSortBy, err)
+		}
+	}
+
+	return nil
+}
+
+// terminalWidth returns the width of w when it is a terminal, or zero
+func terminalWidth(w io.Writer) int {
+	f, ok := w.(*os.File)
+	if !ok || !term.IsTerminal(int(f.Fd())) {
+		return 0
+	}
+
+	width, _, err := term.GetSize(int(f.Fd()))
+	if err != nil {
+		return 0
+	}
+
+	return width
+}
@@ -17,116 +17,167 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"strings"
 
 	"github.com/AlecAivazis/survey/v2"
+	"github.com/AlecAivazis/survey/v2/core"
 	"github.com/edsonmichaque/template-cli/internal/config"
+	"github.com/spf13/viper"
 )
 
-// execConfigPrompt
-func execConfigPrompt(c *config.Config) (*config.Config, string, error) {
-	res, err := execPrompt(
-		execAccountPrompt(c.Account),
-		execAccessTokenPrompt(c.AccessToken),
-		execBaseURLPrompt("https://example.con"),
-		execFileFmtPrompt(cfgFmtJSON),
+const promptConfirmation = "confirmation"
+
+// execConfigPrompt asks for the keys of the schema marked for prompting,
+// defaulting to their values in current, for the file format and for
+// confirmation. It returns current updated with the answers.
+func execConfigPrompt(current map[string]interface{}, format string) (map[string]interface{}, string, error) {
+	var runners []promptRunner
+
+	for _, key := range config.Keys() {
+		if !key.Prompt {
+			continue
+		}
+
+		runners = append(runners, execKeyPrompt(key, current[key.Name], impliedValue(key, current)))
+	}
+
+	if format == "" {
+		format = cfgFmtJSON
+	}
+
+	runners = append(runners,
+		execFileFmtPrompt(format),
 		execConfirmPrompt("Do you want to save?", true),
 	)
+
+	res, err := execPrompt(runners...)
 	if err != nil {
 		return nil, "", err
 	}
 
-	var cfg config.Config
-
-	if value := res.GetString(optAccount); value != "" {
-		cfg.Account = value
+	if !res.GetBool(promptConfirmation) {
+		return nil, "", errors.New("aborted")
 	}
 
-	if value := res.GetString(optAccessToken); value != "" {
-		cfg.AccessToken = value
+	settings := make(map[string]interface{}, len(current))
+	for key, value := range current {
+		settings[key] = value
 	}
 
-	if value := res.GetString(optBaseURL); value != "" {
-		cfg.BaseURL = value
+	for _, key := range config.Keys() {
+		if value := res.Get(key.Name); value != nil && value != "" {
+			settings[key.Name] = value
+		}
 	}
 
-	var format string
-	if value := res.GetString(optFormat); value != "" {
-		format = value
+	return settings, res.GetString(optFormat), nil
+}
+
+// impliedValue returns the value key takes when settings do not set it,
+// which for the environment depends on the sandbox key, or an empty
+// string when it is unknown
+func impliedValue(key config.Key, settings map[string]interface{}) string {
+	if key.Name != config.KeyEnv {
+		return ""
 	}
 
-	fmt.Printf("%#v\n", cfg)
+	sandbox, _ := settings[config.KeySandbox].(bool)
+
+	env, err := config.Config{Sandbox: sandbox}.Environment()
+	if err != nil {
+		return ""
+	}
 
-	return &cfg, format, nil
+	return env.Name
 }
 
-// execAccessTokenPrompt
-func execAccessTokenPrompt(value string) runPromptFunc {
+// execKeyPrompt asks for the value of key, validating it against the
+// schema. Sensitive values are not echoed and keep value when left empty.
+// When value is empty, keys with options default to implied, which is
+// answered as empty so the key stays unset unless another option is
+// chosen.
+func execKeyPrompt(key config.Key, value interface{}, implied string) runPromptFunc {
 	return runPromptFunc(func() (*promptRunnerResult, error) {
-		var token string
+		if key.Type == config.TypeBool {
+			current, _ := value.(bool)
 
-		if err := survey.AskOne(
-			&survey.Input{
-				Message: "Access token",
-				Default: value,
-			},
-			&token,
-		); err != nil {
-			return nil, err
+			var answer bool
+
+			if err := survey.AskOne(
+				&survey.Confirm{
+					Message: key.Description,
+					Default: current,
+				},
+				&answer,
+			); err != nil {
+				return nil, err
+			}
+
+			return &promptRunnerResult{
+				Name:  key.Name,
+				Value: answer,
+			}, nil
 		}
 
-		return &promptRunnerResult{
-			Name:  optAccessToken,
-			Value: token,
-		}, nil
-	})
-}
+		current, _ := value.(string)
 
-// execAccountPrompt
-func execAccountPrompt(value string) runPromptFunc {
-	return runPromptFunc(func() (*promptRunnerResult, error) {
-		var account string
+		var prompt survey.Prompt = &survey.Input{
+			Message: key.Description,
+			Default: current,
+		}
 
-		if err := survey.AskOne(
-			&survey.Input{
-				Message: "Account",
-				Default: value,
-			},
-			&account,
-		); err != nil {
-			return nil, err
+		if key.Options != nil {
+			def := current
+			if def == "" {
+				def = implied
+			}
+
+			prompt = &survey.Select{
+				Message: key.Description,
+				Options: key.Options(),
+				Default: def,
+			}
 		}
 
-		return &promptRunnerResult{
-			Name:  optAccount,
-			Value: account,
-		}, nil
-	})
-}
+		if key.Sensitive {
+			prompt = &survey.Password{
+				Message: key.Description,
+			}
+		}
 
-// execEnvPrompt
-func execEnvPrompt(value string) runPromptFunc {
-	return runPromptFunc(func() (*promptRunnerResult, error) {
-		var env string
+		var answer string
 
 		if err := survey.AskOne(
-			&survey.Select{
-				Message: "Environment",
-				Options: []string{
-					envProd,
-					envSandbox,
-					envDev,
-				},
-				Default: value,
-			},
-			&env,
+			prompt,
+			&answer,
+			survey.WithValidator(func(ans interface{}) error {
+				value, ok := ans.(string)
+				if option, isOption := ans.(core.OptionAnswer); isOption {
+					value, ok = option.Value, true
+				}
+
+				if !ok {
+					return fmt.Errorf("unexpected answer %v", ans)
+				}
+
+				_, err := key.Parse(value)
+
+				return err
+			}),
 		); err != nil {
 			return nil, err
 		}
 
+		if answer == "" || (current == "" && answer == implied) {
+			answer = current
+		}
+
 		return &promptRunnerResult{
-			Name:  optAccessToken,
-			Value: env,
+			Name:  key.Name,
+			Value: answer,
 		}, nil
 	})
 }
@@ -188,7 +239,7 @@ func execConfirmPrompt(msg string, value bool) runPromptFunc {
 		if err := survey.AskOne(
 			&survey.Confirm{
 				Message: msg,
-				Default: false,
+				Default: value,
 			},
 			&confirmation,
 		); err != nil {
@@ -196,12 +247,69 @@ func execConfirmPrompt(msg string, value bool) runPromptFunc {
 		}
 
 		return &promptRunnerResult{
-			Name:  "confirmation",
+			Name:  promptConfirmation,
 			Value: confirmation,
 		}, nil
 	})
 }
 
+// confirmAction asks msg unless the confirm flag is set, failing when
+// prompts are disabled
+func confirmAction(msg string) (bool, error) {
+	if viper.GetBool(optConfirm) {
+		return true, nil
+	}
+
+	if viper.GetBool(optNoInteractive) {
+		return false, fmt.Errorf(`confirmation required, use flag "%s"`, optConfirm)
+	}
+
+	resp, err := execPrompt(execConfirmPrompt(msg, false))
+	if err != nil {
+		return false, err
+	}
+
+	return resp.GetBool(promptConfirmation), nil
+}
+
+// readPassphrase returns the passphrase set in env or asks for it,
+// twice when confirm is set, failing when prompts are disabled
+func readPassphrase(env, msg string, confirm bool) (string, error) {
+	if passphrase := os.Getenv(env); passphrase != "" {
+		return passphrase, nil
+	}
+
+	if viper.GetBool(optNoInteractive) {
+		return "", fmt.Errorf("passphrase required, set %s", env)
+	}
+
+	var passphrase string
+
+	if err := survey.AskOne(
+		&survey.Password{Message: msg},
+		&passphrase,
+		survey.WithValidator(survey.Required),
+	); err != nil {
+		return "", err
+	}
+
+	if !confirm {
+		return passphrase, nil
+	}
+
+	var again string
+
+	if err := survey.AskOne(&survey.Password{Message: "Repeat " + strings.ToLower(msg)}, &again); err != nil {
+		return "", err
+	}
+
+	if again != passphrase {
+		return "", errors.New("passphrases do not match")
+	}
+
+	return passphrase, nil
+}
+
 // promptRunnerResult
 type promptRunnerResult struct {
 	Name  string
@@ -18,10 +18,22 @@ package config
 
 import (
 	"errors"
+	"fmt"
+	"os"
+	"strings"
 
 	"github.com/spf13/viper"
 )
 
+const (
+	maskVisible = 4
+	maskPrefix  = "****"
+
+	// Masked stands for a secret that is set but not read, such as an
+	// access token kept by a credential helper
+	Masked = maskPrefix
+)
+
 // InitWithValidation
 func InitWithValidation() (*Config, error) {
 	return initConfig(true)
@@ -31,13 +43,36 @@ func Init(validate bool) (*Config, error) {
 	return initConfig(validate)
 }
 
+// warnFunc reports problems that do not prevent loading the configuration
+var warnFunc = func(msg string) {
+	fmt.Fprintf(os.Stderr, "Warning: %s\n", msg)
+}
+
+// SetWarnFunc replaces how warnings are reported, by default on stderr
+func SetWarnFunc(fn func(msg string)) {
+	warnFunc = fn
+}
+
 func initConfig(validate bool) (*Config, error) {
-	var cfg Config
-	if err := viper.Unmarshal(&cfg); err != nil {
+	if loadErr != nil {
+		return nil, loadErr
+	}
+
+	if err := checkVersions(); err != nil {
+		return nil, err
+	}
+
+	cfg := fromViper(viper.GetViper())
+
+	if err := cfg.checkPolicy(); err != nil {
 		return nil, err
 	}
 
 	if validate {
+		if err := cfg.ResolveToken(); err != nil {
+			return nil, err
+		}
+
 		if err := cfg.validate(); err != nil {
 			return nil, err
 		}
@@ -47,20 +82,321 @@ func initConfig(validate bool) (*Config, error) {
 }
 
 type Config struct {
-	Account     string `mapstructure:"account"`
-	Sandbox     bool   `mapstructure:"sandbox"`
-	AccessToken string `mapstructure:"access-token"`
-	BaseURL     string `mapstructure:"base-url"`
+	Account          string
+	Sandbox          bool
+	AccessToken      string
+	BaseURL          string
+	CredentialHelper string
+	Env              string
+
+	tokenSource Source
+	tokenOrigin string
+}
+
+// fromViper reads the keys of the schema resolved by v
+func fromViper(v *viper.Viper) Config {
+	return Config{
+		Account:          v.GetString(KeyAccount),
+		Sandbox:          v.GetBool(KeySandbox),
+		AccessToken:      v.GetString(KeyAccessToken),
+		BaseURL:          v.GetString(KeyBaseURL),
+		CredentialHelper: v.GetString(KeyCredentialHelper),
+		Env:              v.GetString(KeyEnv),
+	}
+}
+
+// ResolveToken reads the access token from the credential helper, or else
+// from the encrypted credentials file, when no layer sets it. Init only
+// resolves it when validating, as the helper may ask for input and the
+// credentials file for the passphrase. A helper storing no token leaves it
+// unset.
+func (c *Config) ResolveToken() error {
+	if c.AccessToken != "" {
+		return nil
+	}
+
+	if helper, ok := c.Helper(); ok {
+		token, err := helper.Get(c.Credential())
+		if errors.Is(err, ErrNoToken) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		c.AccessToken, c.tokenSource, c.tokenOrigin = token, SourceHelper, c.CredentialHelper
+
+		return nil
+	}
+
+	token, err := encryptedToken(loaded.Profile)
+	if err != nil || token == "" {
+		return err
+	}
+
+	path, err := CredentialsPath()
+	if err != nil {
+		return err
+	}
+
+	c.AccessToken, c.tokenSource, c.tokenOrigin = token, SourceCredentials, path
+
+	return nil
+}
+
+// TokenOrigin returns where the access token was read from when no layer
+// sets it: the credential helper or the encrypted credentials file
+func (c Config) TokenOrigin() (Source, string, bool) {
+	return c.tokenSource, c.tokenOrigin, c.tokenSource != ""
+}
+
+// HasTokenSource reports whether the access token is set, or kept by a
+// credential helper or in the encrypted credentials file, without asking
+// either of them for it. The credentials file may hold no token for the
+// profile, which is only known once it is decrypted.
+func (c Config) HasTokenSource() (bool, error) {
+	if c.AccessToken != "" {
+		return true, nil
+	}
+
+	if _, ok := c.Helper(); ok {
+		return true, nil
+	}
+
+	return HasCredentials()
+}
+
+// Settings returns the keys of the schema set in c
+func (c Config) Settings() map[string]interface{} {
+	settings := c.allSettings()
+
+	for key, value := range settings {
+		if value == "" || value == false {
+			delete(settings, key)
+		}
+	}
+
+	return settings
 }
 
+// allSettings returns every key of the schema, set or not
+func (c Config) allSettings() map[string]interface{} {
+	return map[string]interface{}{
+		KeyAccount:          c.Account,
+		KeySandbox:          c.Sandbox,
+		KeyAccessToken:      c.AccessToken,
+		KeyBaseURL:          c.BaseURL,
+		KeyCredentialHelper: c.CredentialHelper,
+		KeyEnv:              c.Env,
+	}
+}
+
+// validate checks required keys and per-key validators
 func (c Config) validate() error {
-	if c.Account == "" {
-		return errors.New("account id is required")
+	settings := c.Settings()
+
+	for _, key := range schema {
+		value, ok := settings[key.Name]
+		if !ok {
+			if key.Required {
+				return fmt.Errorf("%s is required", strings.ToLower(key.Description))
+			}
+
+			continue
+		}
+
+		if err := key.Check(value); err != nil {
+			return err
+		}
+	}
+
+	_, err := c.Environment()
+
+	return err
+}
+
+// ValidateFile parses the configuration file at path and checks it as
+// Init would: its version, the type and validator of every key it sets,
+// the policy, the profiles it extends and the required keys, which may
+// come from any source of Init
+func ValidateFile(path string) error {
+	v := viper.New()
+	v.SetConfigFile(path)
+
+	if err := v.ReadInConfig(); err != nil {
+		return err
+	}
+
+	settings := v.AllSettings()
+
+	version, err := FileVersionOf(settings)
+	if err != nil {
+		return err
 	}
 
-	if c.AccessToken == "" {
-		return errors.New("access token is required")
+	if version > FileVersion {
+		return newerVersionError(path, version)
+	}
+
+	for _, key := range schema {
+		value, ok := settings[key.Name]
+		if !ok {
+			continue
+		}
+
+		if _, err := key.Parse(fmt.Sprint(value)); err != nil {
+			return err
+		}
+	}
+
+	if err := CheckPolicy(settings); err != nil {
+		return err
+	}
+
+	if name, _ := settings[KeyEnv].(string); name != "" {
+		envs, err := environments(v)
+		if err != nil {
+			return err
+		}
+
+		if _, err := findEnvironment(envs, name); err != nil {
+			if _, err := LookupEnvironment(name); err != nil {
+				return err
+			}
+		}
+	}
+
+	missing, err := MissingKeys(path, settings)
+	if err != nil {
+		return err
+	}
+
+	if len(missing) > 0 {
+		return MissingKeysError(missing)
 	}
 
 	return nil
 }
+
+// MissingKeys returns the required keys Init would not find once the
+// profile file at path holds settings. Like Init, it looks them up in
+// settings, the profiles they extend, the system and project files, the
+// environment and the policy. The access token is not required when a
+// credential helper is set or the encrypted credentials file exists.
+func MissingKeys(path string, settings map[string]interface{}) ([]string, error) {
+	settings, err := resolvedSettings(path, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []string
+
+	for _, key := range schema {
+		if !key.Required {
+			continue
+		}
+
+		if value, ok := settings[key.Name]; ok && value != "" {
+			continue
+		}
+
+		if key.Name == KeyAccessToken {
+			if helper, _ := settings[KeyCredentialHelper].(string); helper != "" {
+				continue
+			}
+
+			ok, err := HasCredentials()
+			if err != nil {
+				return nil, err
+			}
+
+			if ok {
+				continue
+			}
+		}
+
+		missing = append(missing, key.Name)
+	}
+
+	return missing, nil
+}
+
+// resolvedSettings merges settings, held by the profile file at path, with
+// the other sources of Init: the profiles they extend, the system and
+// project files loaded by Load, the environment and the locked keys
+func resolvedSettings(path string, settings map[string]interface{}) (map[string]interface{}, error) {
+	parents, err := parentLayers(path, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []Layer
+
+	for _, layer := range layers {
+		if layer.Source != SourceProfile {
+			files = append(files, layer)
+		}
+	}
+
+	files = append(append(files, parents...), Layer{Values: settings})
+
+	resolved := make(map[string]interface{})
+
+	for _, layer := range files {
+		for key, value := range layer.Values {
+			resolved[key] = value
+		}
+	}
+
+	for _, key := range schema {
+		for _, env := range key.Env {
+			if value := os.Getenv(env); value != "" {
+				resolved[key.Name] = value
+			}
+		}
+	}
+
+	for key, value := range policy.Locked {
+		resolved[key] = value
+	}
+
+	return resolved, nil
+}
+
+// MissingKeysError reports required keys that are not set
+func MissingKeysError(keys []string) error {
+	return fmt.Errorf("missing required keys: %s", strings.Join(keys, ", "))
+}
+
+// String masks the access token, so printing a Config never leaks it
+func (c Config) String() string {
+	type config Config
+
+	c.AccessToken = Mask(c.AccessToken)
+
+	return fmt.Sprintf("%+v", config(c))
+}
+
+// GoString masks the access token, so printing a Config never leaks it
+func (c Config) GoString() string {
+	type config Config
+
+	c.AccessToken = Mask(c.AccessToken)
+
+	return fmt.Sprintf("%#v", config(c))
+}
+
+// Mask hides all but the last 4 characters of a secret. Short secrets
+// are hidden entirely.
+func Mask(secret string) string {
+	if secret == "" {
+		return ""
+	}
+
+	if len(secret) <= maskVisible*2 {
+		return maskPrefix
+	}
+
+	return maskPrefix + secret[len(secret)-maskVisible:]
+}
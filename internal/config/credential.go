@@ -0,0 +1,341 @@
+// Copyright 2023 Edson Michaque
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/spf13/viper"
+)
+
+// Verbs understood by credential helpers
+const (
+	HelperGet   = "get"
+	HelperStore = "store"
+	HelperErase = "erase"
+)
+
+// ErrNoToken is returned by credential helpers storing no access token
+var ErrNoToken = errors.New("credential helper returned no access token")
+
+// Credential is exchanged with credential helpers as a JSON document.
+// AccessToken is sent to store and returned by get.
+type Credential struct {
+	Profile     string `json:"profile,omitempty"`
+	Account     string `json:"account,omitempty"`
+	BaseURL     string `json:"base_url,omitempty"`
+	AccessToken string `json:"access_token,omitempty"`
+}
+
+// CredentialHelper is an external program storing access tokens, so they
+// are kept out of configuration files. The command is run by the shell
+// with the verb as its last argument, receives a Credential on stdin and,
+// for get, writes a Credential to stdout. Its stderr is passed through,
+// so it can report errors or prompt on the terminal.
+type CredentialHelper struct {
+	Command string
+}
+
+// Get returns the access token the helper stores for c
+func (h CredentialHelper) Get(c Credential) (string, error) {
+	out, err := h.run(HelperGet, c)
+	if err != nil {
+		return "", err
+	}
+
+	var resp Credential
+	if err := json.Unmarshal(out, &resp); err != nil {
+		return "", fmt.Errorf("credential helper: invalid response: %w", err)
+	}
+
+	if resp.AccessToken == "" {
+		return "", ErrNoToken
+	}
+
+	return resp.AccessToken, nil
+}
+
+// Store saves the access token of c in the helper
+func (h CredentialHelper) Store(c Credential) error {
+	_, err := h.run(HelperStore, c)
+
+	return err
+}
+
+// Erase removes the access token of c from the helper
+func (h CredentialHelper) Erase(c Credential) error {
+	_, err := h.run(HelperErase, c)
+
+	return err
+}
+
+func (h CredentialHelper) run(verb string, c Credential) ([]byte, error) {
+	in, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+
+	cmd := exec.Command("sh", "-c", h.Command+` "$@"`, "sh", verb)
+	cmd.Stdin = bytes.NewReader(in)
+	cmd.Stdout = &out
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("credential helper %s: %w", verb, err)
+	}
+
+	return out.Bytes(), nil
+}
+
+// Helper returns the credential helper configured in c, if any
+func (c Config) Helper() (CredentialHelper, bool) {
+	if c.CredentialHelper == "" {
+		return CredentialHelper{}, false
+	}
+
+	return CredentialHelper{Command: c.CredentialHelper}, true
+}
+
+// Credential describes the access token of c for credential helpers
+func (c Config) Credential() Credential {
+	return Credential{
+		Profile:     loaded.Profile,
+		Account:     c.Account,
+		BaseURL:     c.BaseURL,
+		AccessToken: c.AccessToken,
+	}
+}
+
+// StoreToken hands the access token in settings to the credential helper
+// of the resolved configuration, as overridden by settings, or else to the
+// encrypted credentials file when it exists. The token is then removed
+// from settings so it is not written to a profile file. It does nothing
+// when neither is in use.
+func StoreToken(settings map[string]interface{}) error {
+	stored, err := storesToken(settings)
+	if err != nil || !stored {
+		return err
+	}
+
+	cfg := withSettings(fromViper(viper.GetViper()), settings)
+
+	if helper, ok := cfg.Helper(); ok {
+		if err := helper.Store(cfg.Credential()); err != nil {
+			return err
+		}
+	} else if err := storeEncryptedToken(loaded.Profile, cfg.AccessToken); err != nil {
+		return err
+	}
+
+	delete(settings, KeyAccessToken)
+
+	return nil
+}
+
+// storesToken reports whether StoreToken keeps the access token in
+// settings out of the file, with a credential helper or the encrypted
+// credentials file
+func storesToken(settings map[string]interface{}) (bool, error) {
+	cfg := withSettings(fromViper(viper.GetViper()), settings)
+	if cfg.AccessToken == "" {
+		return false, nil
+	}
+
+	if _, ok := cfg.Helper(); ok {
+		return true, nil
+	}
+
+	return HasCredentials()
+}
+
+// EraseToken asks the credential helper of the resolved configuration, if
+// any, to remove the access token, or else removes it from the encrypted
+// credentials file
+func EraseToken() error {
+	cfg := fromViper(viper.GetViper())
+
+	if helper, ok := cfg.Helper(); ok {
+		cfg.AccessToken = ""
+
+		return helper.Erase(cfg.Credential())
+	}
+
+	ok, err := HasCredentials()
+	if err != nil || !ok {
+		return err
+	}
+
+	return storeEncryptedToken(loaded.Profile, "")
+}
+
+// withSettings returns c with the string keys sent to credential helpers
+// replaced by those in settings
+func withSettings(c Config, settings map[string]interface{}) Config {
+	fields := map[string]*string{
+		KeyAccount:          &c.Account,
+		KeyAccessToken:      &c.AccessToken,
+		KeyBaseURL:          &c.BaseURL,
+		KeyCredentialHelper: &c.CredentialHelper,
+	}
+
+	for key, field := range fields {
+		if value, ok := settings[key].(string); ok {
+			*field = value
+		}
+	}
+
+	return c
+}
+
+// transferToken copies the access token of profile from, whose file is at
+// path, to profile to, in its credential helper and in the encrypted
+// credentials file. Unless keep is set, the token of from is then erased.
+// An empty to only erases it.
+func transferToken(path, from, to string, keep bool) error {
+	helper, cred, ok, err := profileHelper(path)
+	if err != nil {
+		return err
+	}
+
+	if ok {
+		if err := transferHelperToken(helper, cred, from, to, keep); err != nil {
+			return err
+		}
+	}
+
+	exists, err := HasCredentials()
+	if err != nil || !exists {
+		return err
+	}
+
+	passphrase, err := passphraseFunc()
+	if err != nil {
+		return err
+	}
+
+	tokens, err := ReadCredentials(passphrase)
+	if err != nil {
+		return err
+	}
+
+	token, ok := tokens[from]
+	if !ok {
+		return nil
+	}
+
+	if to != "" {
+		tokens[to] = token
+	}
+
+	if !keep {
+		delete(tokens, from)
+	}
+
+	return WriteCredentials(tokens, passphrase)
+}
+
+func transferHelperToken(helper CredentialHelper, cred Credential, from, to string, keep bool) error {
+	cred.Profile = from
+
+	token, err := helper.Get(cred)
+	if errors.Is(err, ErrNoToken) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if to != "" {
+		stored := cred
+		stored.Profile, stored.AccessToken = to, token
+
+		if err := helper.Store(stored); err != nil {
+			return err
+		}
+	}
+
+	if keep {
+		return nil
+	}
+
+	return helper.Erase(cred)
+}
+
+// profileHelper returns the credential helper of the profile file at path,
+// as set by the environment, the file, the profiles it extends or the
+// system file, and the credential describing its access token
+func profileHelper(path string) (CredentialHelper, Credential, bool, error) {
+	layer, err := readLayer(SourceProfile, path)
+	if err != nil {
+		return CredentialHelper{}, Credential{}, false, err
+	}
+
+	parents, err := parentLayers(path, layer.Values)
+	if err != nil {
+		return CredentialHelper{}, Credential{}, false, err
+	}
+
+	settings := make(map[string]interface{})
+
+	for _, l := range append(systemLayers(), append(parents, *layer)...) {
+		for key, value := range l.Values {
+			settings[key] = value
+		}
+	}
+
+	key, err := LookupKey(KeyCredentialHelper)
+	if err != nil {
+		return CredentialHelper{}, Credential{}, false, err
+	}
+
+	for _, env := range key.Env {
+		if s := os.Getenv(env); s != "" {
+			settings[KeyCredentialHelper] = s
+		}
+	}
+
+	cfg := withSettings(Config{}, settings)
+	cred := Credential{
+		Account: cfg.Account,
+		BaseURL: cfg.BaseURL,
+	}
+
+	helper, ok := cfg.Helper()
+
+	return helper, cred, ok, nil
+}
+
+// systemLayers returns the system layers merged by Load
+func systemLayers() []Layer {
+	var system []Layer
+
+	for _, layer := range layers {
+		if layer.Source == SourceSystem {
+			system = append(system, layer)
+		}
+	}
+
+	return system
+}
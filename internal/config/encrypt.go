@@ -0,0 +1,286 @@
+// Copyright 2023 Edson Michaque
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	credentialsFile = "credentials.enc"
+	EnvPassphrase   = "TEMPLATE_PASSPHRASE"
+
+	kdfScrypt      = "scrypt"
+	scryptN        = 1 << 15
+	scryptR        = 8
+	scryptP        = 1
+	scryptSaltSize = 16
+	scryptKeySize  = 32
+
+	// bounds of the scrypt parameters read from the credentials file, so a
+	// tampered file cannot make key derivation exhaust memory or time
+	scryptMaxN = 1 << 20
+	scryptMaxR = 32
+	scryptMaxP = 16
+)
+
+var ErrWrongPassphrase = errors.New("wrong passphrase or corrupted credentials file")
+
+// passphraseFunc returns the passphrase of the credentials file
+var passphraseFunc = func() (string, error) {
+	if passphrase := os.Getenv(EnvPassphrase); passphrase != "" {
+		return passphrase, nil
+	}
+
+	return "", fmt.Errorf("passphrase required, set %s", EnvPassphrase)
+}
+
+// SetPassphraseFunc replaces how the passphrase of the credentials file is
+// obtained when Init needs to decrypt it. By default it is read from
+// TEMPLATE_PASSPHRASE.
+func SetPassphraseFunc(fn func() (string, error)) {
+	passphraseFunc = fn
+}
+
+// envelope is the content of the credentials file. The key is derived
+// from the passphrase with scrypt and the tokens, keyed by profile, are
+// sealed with AES-256-GCM.
+type envelope struct {
+	KDF        string `json:"kdf"`
+	N          int    `json:"n"`
+	R          int    `json:"r"`
+	P          int    `json:"p"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// CredentialsPath returns the path of the encrypted credentials file
+func CredentialsPath() (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, credentialsFile), nil
+}
+
+// HasCredentials reports whether the encrypted credentials file exists
+func HasCredentials() (bool, error) {
+	path, err := CredentialsPath()
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return true, nil
+}
+
+// ReadCredentials decrypts the access tokens, keyed by profile, stored in
+// the credentials file. It returns no tokens when the file does not exist.
+func ReadCredentials(passphrase string) (map[string]string, error) {
+	path, err := CredentialsPath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return map[string]string{}, nil
+		}
+
+		return nil, err
+	}
+
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if env.KDF != kdfScrypt {
+		return nil, fmt.Errorf("%s: unsupported key derivation %q", path, env.KDF)
+	}
+
+	if !validScryptParams(env.N, env.R, env.P) {
+		return nil, fmt.Errorf("%s: unsupported scrypt parameters n=%d r=%d p=%d", path, env.N, env.R, env.P)
+	}
+
+	aead, err := newAEAD(passphrase, env.Salt, env.N, env.R, env.P)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(env.Nonce) != aead.NonceSize() {
+		return nil, ErrWrongPassphrase
+	}
+
+	plaintext, err := aead.Open(nil, env.Nonce, env.Ciphertext, nil)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+
+	tokens := make(map[string]string)
+	if err := json.Unmarshal(plaintext, &tokens); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// WriteCredentials encrypts tokens, keyed by profile, into the credentials
+// file with a fresh salt. The file is removed when tokens is empty.
+func WriteCredentials(tokens map[string]string, passphrase string) error {
+	path, err := CredentialsPath()
+	if err != nil {
+		return err
+	}
+
+	if len(tokens) == 0 {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+
+		return nil
+	}
+
+	if passphrase == "" {
+		return errors.New("passphrase cannot be empty")
+	}
+
+	plaintext, err := json.Marshal(tokens)
+	if err != nil {
+		return err
+	}
+
+	env := envelope{
+		KDF:  kdfScrypt,
+		N:    scryptN,
+		R:    scryptR,
+		P:    scryptP,
+		Salt: make([]byte, scryptSaltSize),
+	}
+
+	if _, err := rand.Read(env.Salt); err != nil {
+		return err
+	}
+
+	aead, err := newAEAD(passphrase, env.Salt, env.N, env.R, env.P)
+	if err != nil {
+		return err
+	}
+
+	env.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(env.Nonce); err != nil {
+		return err
+	}
+
+	env.Ciphertext = aead.Seal(nil, env.Nonce, plaintext, nil)
+
+	data, err := json.MarshalIndent(env, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeAtomic(path, secretFilePerms, nil, func(f *os.File) error {
+		_, err := f.Write(append(data, '\n'))
+
+		return err
+	})
+}
+
+// encryptedToken returns the access token of profile stored in the
+// credentials file, asking for the passphrase only when the file exists
+func encryptedToken(profile string) (string, error) {
+	ok, err := HasCredentials()
+	if err != nil || !ok {
+		return "", err
+	}
+
+	passphrase, err := passphraseFunc()
+	if err != nil {
+		return "", err
+	}
+
+	tokens, err := ReadCredentials(passphrase)
+	if err != nil {
+		return "", err
+	}
+
+	return tokens[profile], nil
+}
+
+// storeEncryptedToken sets or, when token is empty, removes the access
+// token of profile in the credentials file
+func storeEncryptedToken(profile, token string) error {
+	passphrase, err := passphraseFunc()
+	if err != nil {
+		return err
+	}
+
+	tokens, err := ReadCredentials(passphrase)
+	if err != nil {
+		return err
+	}
+
+	if token == "" {
+		delete(tokens, profile)
+	} else {
+		tokens[profile] = token
+	}
+
+	return WriteCredentials(tokens, passphrase)
+}
+
+// validScryptParams reports whether n is a power of two and n, r and p
+// are within the bounds accepted from the credentials file
+func validScryptParams(n, r, p int) bool {
+	return n > 1 && n <= scryptMaxN && n&(n-1) == 0 &&
+		r > 0 && r <= scryptMaxR &&
+		p > 0 && p <= scryptMaxP
+}
+
+func newAEAD(passphrase string, salt []byte, n, r, p int) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, n, r, p, scryptKeySize)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
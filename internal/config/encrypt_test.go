@@ -0,0 +1,172 @@
+// Copyright 2023 Edson Michaque
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// setConfigHome points the user configuration directory to a temporary
+// directory
+func setConfigHome(t *testing.T) string {
+	t.Helper()
+
+	home := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", home)
+
+	return home
+}
+
+func TestCredentialsRoundTrip(t *testing.T) {
+	setConfigHome(t)
+
+	tokens := map[string]string{
+		"main":    "main-token",
+		"staging": "staging-token",
+	}
+
+	require.NoError(t, WriteCredentials(tokens, "passphrase"))
+
+	path, err := CredentialsPath()
+	require.NoError(t, err)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NotContains(t, string(data), "main-token")
+
+	got, err := ReadCredentials("passphrase")
+	require.NoError(t, err)
+	require.Equal(t, tokens, got)
+}
+
+func TestReadCredentials(t *testing.T) {
+	type testcase struct {
+		passphrase string
+		tamper     func(*envelope)
+		err        error
+		errMsg     string
+	}
+
+	tt := map[string]testcase{
+		"wrong passphrase": {
+			passphrase: "wrong",
+			err:        ErrWrongPassphrase,
+		},
+		"tampered ciphertext": {
+			passphrase: "passphrase",
+			tamper: func(env *envelope) {
+				env.Ciphertext[0] ^= 0xff
+			},
+			err: ErrWrongPassphrase,
+		},
+		"truncated nonce": {
+			passphrase: "passphrase",
+			tamper: func(env *envelope) {
+				env.Nonce = env.Nonce[:4]
+			},
+			err: ErrWrongPassphrase,
+		},
+		"unbounded cost": {
+			passphrase: "passphrase",
+			tamper: func(env *envelope) {
+				env.N = 1 << 30
+			},
+			errMsg: "unsupported scrypt parameters",
+		},
+		"cost not a power of two": {
+			passphrase: "passphrase",
+			tamper: func(env *envelope) {
+				env.N = 3
+			},
+			errMsg: "unsupported scrypt parameters",
+		},
+		"unbounded parallelism": {
+			passphrase: "passphrase",
+			tamper: func(env *envelope) {
+				env.P = 1 << 10
+			},
+			errMsg: "unsupported scrypt parameters",
+		},
+		"unknown key derivation": {
+			passphrase: "passphrase",
+			tamper: func(env *envelope) {
+				env.KDF = "argon2"
+			},
+			errMsg: "unsupported key derivation",
+		},
+	}
+
+	for tn, tc := range tt {
+		t.Run(tn, func(t *testing.T) {
+			setConfigHome(t)
+
+			require.NoError(t, WriteCredentials(map[string]string{"main": "token"}, "passphrase"))
+
+			path, err := CredentialsPath()
+			require.NoError(t, err)
+
+			if tc.tamper != nil {
+				data, err := os.ReadFile(path)
+				require.NoError(t, err)
+
+				var env envelope
+				require.NoError(t, json.Unmarshal(data, &env))
+
+				tc.tamper(&env)
+
+				data, err = json.Marshal(env)
+				require.NoError(t, err)
+				require.NoError(t, os.WriteFile(path, data, 0o600))
+			}
+
+			_, err = ReadCredentials(tc.passphrase)
+			if tc.err != nil {
+				require.ErrorIs(t, err, tc.err)
+			} else {
+				require.ErrorContains(t, err, tc.errMsg)
+			}
+		})
+	}
+}
+
+func TestWriteCredentialsRemovesEmptyFile(t *testing.T) {
+	setConfigHome(t)
+
+	require.NoError(t, WriteCredentials(map[string]string{"main": "token"}, "passphrase"))
+
+	ok, err := HasCredentials()
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	require.NoError(t, WriteCredentials(map[string]string{}, "passphrase"))
+
+	ok, err = HasCredentials()
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	tokens, err := ReadCredentials("passphrase")
+	require.NoError(t, err)
+	require.Empty(t, tokens)
+}
@@ -0,0 +1,248 @@
+// Copyright 2023 Edson Michaque
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	EnvProd    = "prod"
+	EnvSandbox = "sandbox"
+
+	// KeyEnvironments is the section of configuration files defining
+	// environments, keyed by name
+	KeyEnvironments = "environments"
+
+	envKeyBaseURL            = "base-url"
+	envKeyAPIVersion         = "api-version"
+	envKeyCAFile             = "tls.ca-file"
+	envKeyInsecureSkipVerify = "tls.insecure-skip-verify"
+
+	defaultAPIVersion = "v1"
+)
+
+// TLS holds the TLS settings used to reach an environment
+type TLS struct {
+	CAFile             string
+	InsecureSkipVerify bool
+}
+
+// Environment is an API deployment the CLI can talk to
+type Environment struct {
+	Name       string
+	BaseURL    string
+	APIVersion string
+	TLS        TLS
+	BuiltIn    bool
+}
+
+// builtinEnvironments are available without configuration. Configuration
+// files may override their settings.
+var builtinEnvironments = []Environment{
+	{
+		Name:       EnvProd,
+		BaseURL:    "https://api.example.com",
+		APIVersion: defaultAPIVersion,
+		BuiltIn:    true,
+	},
+	{
+		Name:       EnvSandbox,
+		BaseURL:    "https://api.sandbox.example.com",
+		APIVersion: defaultAPIVersion,
+		BuiltIn:    true,
+	},
+}
+
+// Environments returns the built-in environments followed by those defined
+// in the resolved configuration, each sorted by name
+func Environments() ([]Environment, error) {
+	return environments(viper.GetViper())
+}
+
+// EnvironmentNames returns the name of every environment, ignoring
+// invalid definitions
+func EnvironmentNames() []string {
+	envs, _ := Environments()
+
+	names := make([]string, 0, len(envs))
+	for _, env := range envs {
+		names = append(names, env.Name)
+	}
+
+	return names
+}
+
+// LookupEnvironment returns the environment called name
+func LookupEnvironment(name string) (Environment, error) {
+	envs, err := Environments()
+	if err != nil {
+		return Environment{}, err
+	}
+
+	return findEnvironment(envs, name)
+}
+
+// CurrentEnvironment returns the environment selected by the resolved
+// configuration
+func CurrentEnvironment() (Environment, error) {
+	return fromViper(viper.GetViper()).Environment()
+}
+
+// Environment returns the environment selected by c: the env key, or the
+// sandbox environment when the sandbox key is set, or else production. A
+// base URL set in c replaces the one of the environment.
+func (c Config) Environment() (Environment, error) {
+	name := c.Env
+	if name == "" {
+		name = EnvProd
+		if c.Sandbox {
+			name = EnvSandbox
+		}
+	}
+
+	env, err := LookupEnvironment(name)
+	if err != nil {
+		return Environment{}, err
+	}
+
+	if c.BaseURL != "" {
+		env.BaseURL = c.BaseURL
+	}
+
+	return env, nil
+}
+
+// TLSConfig returns the TLS configuration used to reach the environment
+func (e Environment) TLSConfig() (*tls.Config, error) {
+	cfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: e.TLS.InsecureSkipVerify,
+	}
+
+	if e.TLS.CAFile == "" {
+		return cfg, nil
+	}
+
+	pem, err := os.ReadFile(e.TLS.CAFile)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("%s: no certificate found", e.TLS.CAFile)
+	}
+
+	cfg.RootCAs = pool
+
+	return cfg, nil
+}
+
+// environments reads the environments defined in v on top of the
+// built-in ones
+func environments(v *viper.Viper) ([]Environment, error) {
+	byName := make(map[string]Environment, len(builtinEnvironments))
+	for _, env := range builtinEnvironments {
+		byName[env.Name] = env
+	}
+
+	for name := range v.GetStringMap(KeyEnvironments) {
+		sub := v.Sub(KeyEnvironments + "." + name)
+		if sub == nil {
+			return nil, fmt.Errorf("%s.%s: expected a map", KeyEnvironments, name)
+		}
+
+		env, ok := byName[name]
+		if !ok {
+			env = Environment{Name: name, APIVersion: defaultAPIVersion}
+		}
+
+		if sub.IsSet(envKeyBaseURL) {
+			env.BaseURL = sub.GetString(envKeyBaseURL)
+		}
+
+		if sub.IsSet(envKeyAPIVersion) {
+			env.APIVersion = sub.GetString(envKeyAPIVersion)
+		}
+
+		if sub.IsSet(envKeyCAFile) {
+			env.TLS.CAFile = sub.GetString(envKeyCAFile)
+		}
+
+		if sub.IsSet(envKeyInsecureSkipVerify) {
+			env.TLS.InsecureSkipVerify = sub.GetBool(envKeyInsecureSkipVerify)
+		}
+
+		if env.BaseURL == "" {
+			return nil, fmt.Errorf("%s.%s: %s is required", KeyEnvironments, name, envKeyBaseURL)
+		}
+
+		if err := validateURL(env.BaseURL); err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", KeyEnvironments, name, err)
+		}
+
+		byName[name] = env
+	}
+
+	envs := make([]Environment, 0, len(byName))
+	for _, env := range byName {
+		envs = append(envs, env)
+	}
+
+	sort.Slice(envs, func(i, j int) bool {
+		if envs[i].BuiltIn != envs[j].BuiltIn {
+			return envs[i].BuiltIn
+		}
+
+		return envs[i].Name < envs[j].Name
+	})
+
+	return envs, nil
+}
+
+func findEnvironment(envs []Environment, name string) (Environment, error) {
+	for _, env := range envs {
+		if env.Name == name {
+			return env, nil
+		}
+	}
+
+	names := make([]string, 0, len(envs))
+	for _, env := range envs {
+		names = append(names, env.Name)
+	}
+
+	return Environment{}, fmt.Errorf("unknown environment %q, expected one of: %s", name, strings.Join(names, ", "))
+}
+
+func validateEnvironmentName(value interface{}) error {
+	s, _ := value.(string)
+	if strings.ContainsAny(s, ". ") {
+		return errors.New("environment name cannot contain dots or spaces")
+	}
+
+	return nil
+}
@@ -0,0 +1,337 @@
+// Copyright 2023 Edson Michaque
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Source identifies where a configuration value comes from
+type Source string
+
+const (
+	SourceDefault     = Source("default")
+	SourceSystem      = Source("system")
+	SourceProfile     = Source("profile")
+	SourceProject     = Source("project")
+	SourceEnv         = Source("env")
+	SourceFlag        = Source("flag")
+	SourceHelper      = Source("credential-helper")
+	SourceCredentials = Source("credentials")
+	SourcePolicy      = Source("policy")
+)
+
+const (
+	projectFileName = ".template"
+	systemFileName  = "config"
+
+	// keyViews holds the named output templates
+	keyViews = "views"
+)
+
+// projectKeys lists the keys project files may set. Project files come
+// with the repositories they are checked into, so they cannot set keys
+// that send the access token elsewhere, weaken TLS or run commands.
+var projectKeys = []string{KeyAccount, KeyEnv, KeySandbox, KeyVersion, keyViews}
+
+// Layer is a configuration file merged into the resolved configuration
+type Layer struct {
+	Source Source
+	Path   string
+	Values map[string]interface{}
+}
+
+// LoadOpts selects the files merged by Load. ConfigFile, when set,
+// replaces the file of Profile.
+type LoadOpts struct {
+	ConfigFile string
+	Profile    string
+	SystemDir  string
+	WorkDir    string
+}
+
+var (
+	layers  []Layer
+	loaded  LoadOpts
+	loadErr error
+)
+
+// Load merges into viper, from lowest to highest precedence, the built-in
+// defaults, the system file, the profiles extended by the profile file,
+// the profile file and the nearest project file. Environment variables
+// and flags are bound by the commands and take precedence over every
+// file. Keys locked by the system policy override all of them.
+func Load(opts LoadOpts) error {
+	loadErr = load(opts)
+
+	// locked keys are applied even when a file cannot be read, so a broken
+	// file cannot lift the policy
+	applyPolicy()
+
+	return loadErr
+}
+
+func load(opts LoadOpts) error {
+	for _, key := range schema {
+		if key.Default != nil {
+			viper.SetDefault(key.Name, key.Default)
+		}
+
+		if err := viper.BindEnv(append([]string{key.Name}, key.Env...)...); err != nil {
+			return err
+		}
+	}
+
+	finders := []func(LoadOpts) ([]Layer, error){
+		single(systemLayer),
+		profileLayers,
+		single(projectLayer),
+	}
+
+	layers = nil
+	loaded = opts
+
+	policy, policyErr = readPolicy(opts.SystemDir)
+	if policyErr != nil {
+		return policyErr
+	}
+
+	for _, find := range finders {
+		found, err := find(opts)
+		if err != nil {
+			return err
+		}
+
+		for _, layer := range found {
+			if err := viper.MergeConfigMap(layer.Values); err != nil {
+				return fmt.Errorf("%s: %w", layer.Path, err)
+			}
+
+			layers = append(layers, layer)
+		}
+	}
+
+	checkPermissions()
+
+	return nil
+}
+
+// Layers returns the files merged by Load, lowest precedence first
+func Layers() []Layer {
+	return layers
+}
+
+func systemLayer(opts LoadOpts) (*Layer, error) {
+	if opts.SystemDir == "" {
+		return nil, nil
+	}
+
+	path, err := findFile(opts.SystemDir, systemFileName)
+	if err != nil || path == "" {
+		return nil, err
+	}
+
+	return readLayer(SourceSystem, path)
+}
+
+// single adapts a finder of at most one layer
+func single(find func(LoadOpts) (*Layer, error)) func(LoadOpts) ([]Layer, error) {
+	return func(opts LoadOpts) ([]Layer, error) {
+		layer, err := find(opts)
+		if err != nil || layer == nil {
+			return nil, err
+		}
+
+		return []Layer{*layer}, nil
+	}
+}
+
+// profileLayers reads the profile file, or the file given by ConfigFile,
+// preceded by the profiles it extends
+func profileLayers(opts LoadOpts) ([]Layer, error) {
+	path := opts.ConfigFile
+	if path == "" {
+		p, err := FindProfile(opts.Profile)
+		if err != nil {
+			if errors.Is(err, ErrProfileNotFound) {
+				return nil, nil
+			}
+
+			return nil, err
+		}
+
+		path = p.Path
+	}
+
+	layer, err := readLayer(SourceProfile, path)
+	if err != nil {
+		return nil, err
+	}
+
+	parents, err := parentLayers(layer.Path, layer.Values)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(parents, *layer), nil
+}
+
+// projectLayer reads the nearest project file, walking up from WorkDir
+func projectLayer(opts LoadOpts) (*Layer, error) {
+	if opts.WorkDir == "" {
+		return nil, nil
+	}
+
+	dir := opts.WorkDir
+
+	for {
+		path, err := findFile(dir, projectFileName)
+		if err != nil {
+			return nil, err
+		}
+
+		if path != "" {
+			return readProjectLayer(path)
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil
+		}
+
+		dir = parent
+	}
+}
+
+// readProjectLayer reads the project file at path, refusing the keys not
+// listed in projectKeys
+func readProjectLayer(path string) (*Layer, error) {
+	layer, err := readLayer(SourceProject, path)
+	if err != nil {
+		return nil, err
+	}
+
+	var refused []string
+
+	for key := range layer.Values {
+		if !contains(projectKeys, key) {
+			refused = append(refused, key)
+		}
+	}
+
+	if len(refused) > 0 {
+		sort.Strings(refused)
+
+		return nil, fmt.Errorf("%s: %s cannot be set in a project file", path, strings.Join(refused, ", "))
+	}
+
+	return layer, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// findFile returns the path of the file name with a supported format in
+// dir, or an empty path when there is none
+func findFile(dir, name string) (string, error) {
+	for _, ext := range Formats {
+		path := filepath.Join(dir, fmt.Sprintf("%s.%s", name, ext))
+
+		info, err := os.Stat(path)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrPermission) {
+				continue
+			}
+
+			return "", err
+		}
+
+		if !info.IsDir() {
+			return path, nil
+		}
+	}
+
+	return "", nil
+}
+
+func readLayer(source Source, path string) (*Layer, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return &Layer{
+		Source: source,
+		Path:   path,
+		Values: v.AllSettings(),
+	}, nil
+}
+
+// FileOrigin returns the layer setting key, falling back to the built-in
+// defaults. It returns false when no file nor default sets key.
+func FileOrigin(key string) (Source, string, bool) {
+	for i := len(layers) - 1; i >= 0; i-- {
+		if hasKey(layers[i].Values, key) {
+			return layers[i].Source, layers[i].Path, true
+		}
+	}
+
+	if k, err := LookupKey(key); err == nil && k.Default != nil {
+		return SourceDefault, "", true
+	}
+
+	return "", "", false
+}
+
+// hasKey reports whether the dotted key is set in values
+func hasKey(values map[string]interface{}, key string) bool {
+	parts := strings.Split(strings.ToLower(key), ".")
+
+	for i, part := range parts {
+		value, ok := values[part]
+		if !ok {
+			return false
+		}
+
+		if i == len(parts)-1 {
+			return true
+		}
+
+		if values, ok = value.(map[string]interface{}); !ok {
+			return false
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,282 @@
+// Copyright 2023 Edson Michaque
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	// KeyVersion is the key holding the version of a configuration file.
+	// Files without it are at version 0.
+	KeyVersion = "version"
+	// FileVersion is the version of the files written by this release
+	FileVersion = 1
+)
+
+// renamedKeys lists the keys used by files at version 0 and their names,
+// the first one found winning
+var renamedKeys = [][2]string{
+	{"access_token", KeyAccessToken},
+	{"token", KeyAccessToken},
+	{"base_url", KeyBaseURL},
+}
+
+// migration upgrades the settings of a file from version from to the next
+// one, returning a description of every change
+type migration struct {
+	from    int
+	migrate func(profile string, settings map[string]interface{}) ([]string, error)
+}
+
+var migrations = []migration{
+	{from: 0, migrate: migrateV0},
+}
+
+// Migration is the result of MigrateFile
+type Migration struct {
+	Path    string
+	NewPath string
+	Backup  string
+	From    int
+	To      int
+	Changes []string
+}
+
+// Migrated reports whether the file was rewritten
+func (m Migration) Migrated() bool {
+	return m.Backup != ""
+}
+
+// FileVersionOf returns the version of a file with settings
+func FileVersionOf(settings map[string]interface{}) (int, error) {
+	value, ok := settings[KeyVersion]
+	if !ok {
+		return 0, nil
+	}
+
+	version, err := strconv.Atoi(fmt.Sprint(value))
+	if err != nil || version < 0 {
+		return 0, fmt.Errorf("invalid version %v", value)
+	}
+
+	return version, nil
+}
+
+// MigrateFile upgrades the file at path to FileVersion and, when format is
+// set, converts it to format. The original file is first copied next to it
+// with a .v<version>.bak suffix, without the secrets the migration moves
+// out of it, and its BackupSuffix file is removed. profile names the
+// profile of the file, if any, so its access token can be moved to the
+// encrypted credentials file.
+func MigrateFile(path, profile, format string) (*Migration, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+
+	if err := v.ReadInConfig(); err != nil {
+		return nil, err
+	}
+
+	settings := v.AllSettings()
+
+	version, err := FileVersionOf(settings)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if version > FileVersion {
+		return nil, newerVersionError(path, version)
+	}
+
+	m := &Migration{
+		Path:    path,
+		NewPath: path,
+		From:    version,
+		To:      FileVersion,
+	}
+
+	ext := strings.TrimPrefix(filepath.Ext(path), ".")
+	if format != "" && format != ext {
+		if !isFormat(format) {
+			return nil, fmt.Errorf("invalid format %q, expected one of: %s", format, strings.Join(Formats, ", "))
+		}
+
+		m.NewPath = strings.TrimSuffix(path, ext) + format
+
+		if _, err := os.Stat(m.NewPath); err == nil {
+			return nil, fmt.Errorf("%s already exists", m.NewPath)
+		}
+		m.Changes = append(m.Changes, fmt.Sprintf("converted from %s to %s", ext, format))
+	}
+
+	for _, mig := range migrations {
+		if mig.from < version {
+			continue
+		}
+
+		changes, err := mig.migrate(profile, settings)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+
+		m.Changes = append(m.Changes, changes...)
+	}
+
+	if version == FileVersion && m.NewPath == path {
+		return m, nil
+	}
+
+	settings[KeyVersion] = FileVersion
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// secrets the migration moves out of the file, or that the policy
+	// forbids, are not kept in its backups either
+	original := v.AllSettings()
+
+	stripped := withoutSecrets(original)
+	dropSecrets := len(stripped) < len(original) && (!hasSecrets(settings) || !policy.PlaintextTokens)
+
+	if dropSecrets {
+		data, err = encodeSettings(ext, stripped)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	m.Backup = fmt.Sprintf("%s.v%d.bak", path, version)
+
+	err = writeAtomic(m.Backup, secretFilePerms, nil, func(f *os.File) error {
+		_, err := f.Write(data)
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = writeAtomic(m.NewPath, filePermsFor(path, settings), nil, func(f *os.File) error {
+		return writeSettings(f.Name(), settings)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if m.NewPath != path {
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if m.NewPath != path || dropSecrets {
+		if err := removeBackup(path); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+// checkVersions refuses files written by a newer release and warns about
+// outdated profile files
+func checkVersions() error {
+	for _, layer := range layers {
+		version, err := FileVersionOf(layer.Values)
+		if err != nil {
+			return fmt.Errorf("%s: %w", layer.Path, err)
+		}
+
+		if version > FileVersion {
+			return newerVersionError(layer.Path, version)
+		}
+
+		if version < FileVersion && layer.Source == SourceProfile {
+			warnFunc(fmt.Sprintf(`%s is at version %d, run "%s config migrate" to upgrade it to version %d`, layer.Path, version, appName, FileVersion))
+		}
+	}
+
+	return nil
+}
+
+func newerVersionError(path string, version int) error {
+	return fmt.Errorf("%s is at version %d, which is newer than the supported version %d, upgrade %s", path, version, FileVersion, appName)
+}
+
+// migrateV0 renames keys, stores the account as a string, as older
+// releases of config set wrote it as a number, and moves the access token
+// to the encrypted credentials file when it exists
+func migrateV0(profile string, settings map[string]interface{}) ([]string, error) {
+	var changes []string
+
+	for _, rename := range renamedKeys {
+		old, key := rename[0], rename[1]
+
+		value, ok := settings[old]
+		if !ok {
+			continue
+		}
+
+		if _, ok := settings[key]; !ok {
+			settings[key] = value
+		}
+
+		delete(settings, old)
+
+		changes = append(changes, fmt.Sprintf("renamed %s to %s", old, key))
+	}
+
+	if value, ok := settings[KeyAccount]; ok {
+		switch v := value.(type) {
+		case string:
+		case float64:
+			settings[KeyAccount] = strconv.FormatFloat(v, 'f', -1, 64)
+			changes = append(changes, fmt.Sprintf("converted %s to a string", KeyAccount))
+		default:
+			settings[KeyAccount] = fmt.Sprint(v)
+			changes = append(changes, fmt.Sprintf("converted %s to a string", KeyAccount))
+		}
+	}
+
+	token, _ := settings[KeyAccessToken].(string)
+	if profile == "" || token == "" {
+		return changes, nil
+	}
+
+	ok, err := HasCredentials()
+	if err != nil || !ok {
+		return changes, err
+	}
+
+	if err := storeEncryptedToken(profile, token); err != nil {
+		return nil, err
+	}
+
+	delete(settings, KeyAccessToken)
+
+	changes = append(changes, fmt.Sprintf("moved %s to the encrypted credentials file", KeyAccessToken))
+
+	return changes, nil
+}
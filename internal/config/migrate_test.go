@@ -0,0 +1,339 @@
+// Copyright 2023 Edson Michaque
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+// copyFixture copies the file name of testdata/migrate to a temporary
+// directory, returning its new path
+func copyFixture(t *testing.T, name string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("testdata", "migrate", name))
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+
+	return path
+}
+
+// readSettings reads the configuration file at path
+func readSettings(t *testing.T, path string) map[string]interface{} {
+	t.Helper()
+
+	v := viper.New()
+	v.SetConfigFile(path)
+	require.NoError(t, v.ReadInConfig())
+
+	return v.AllSettings()
+}
+
+func TestMigrateV0(t *testing.T) {
+	type testcase struct {
+		settings map[string]interface{}
+		want     map[string]interface{}
+		changes  []string
+	}
+
+	tt := map[string]testcase{
+		"renamed keys": {
+			settings: map[string]interface{}{
+				"access_token": "secret",
+				"base_url":     "https://api.example.com",
+			},
+			want: map[string]interface{}{
+				KeyAccessToken: "secret",
+				KeyBaseURL:     "https://api.example.com",
+			},
+			changes: []string{
+				"renamed access_token to access-token",
+				"renamed base_url to base-url",
+			},
+		},
+		"first renamed key wins": {
+			settings: map[string]interface{}{
+				"access_token": "first",
+				"token":        "second",
+			},
+			want: map[string]interface{}{
+				KeyAccessToken: "first",
+			},
+			changes: []string{
+				"renamed access_token to access-token",
+				"renamed token to access-token",
+			},
+		},
+		"current key wins": {
+			settings: map[string]interface{}{
+				KeyAccessToken: "current",
+				"token":        "old",
+			},
+			want: map[string]interface{}{
+				KeyAccessToken: "current",
+			},
+			changes: []string{"renamed token to access-token"},
+		},
+		"numeric account from json": {
+			settings: map[string]interface{}{KeyAccount: float64(12)},
+			want:     map[string]interface{}{KeyAccount: "12"},
+			changes:  []string{"converted account to a string"},
+		},
+		"numeric account from yaml": {
+			settings: map[string]interface{}{KeyAccount: 12},
+			want:     map[string]interface{}{KeyAccount: "12"},
+			changes:  []string{"converted account to a string"},
+		},
+		"string account": {
+			settings: map[string]interface{}{KeyAccount: "12"},
+			want:     map[string]interface{}{KeyAccount: "12"},
+		},
+	}
+
+	for tn, tc := range tt {
+		t.Run(tn, func(t *testing.T) {
+			setConfigHome(t)
+
+			changes, err := migrateV0("main", tc.settings)
+			require.NoError(t, err)
+			require.Equal(t, tc.want, tc.settings)
+			require.Equal(t, tc.changes, changes)
+		})
+	}
+}
+
+func TestMigrateV0MovesToken(t *testing.T) {
+	setConfigHome(t)
+	t.Setenv(EnvPassphrase, "passphrase")
+
+	require.NoError(t, WriteCredentials(map[string]string{"other": "other-token"}, "passphrase"))
+
+	settings := map[string]interface{}{"token": "secret"}
+
+	changes, err := migrateV0("main", settings)
+	require.NoError(t, err)
+	require.Empty(t, settings)
+	require.Equal(t, []string{
+		"renamed token to access-token",
+		"moved access-token to the encrypted credentials file",
+	}, changes)
+
+	tokens, err := ReadCredentials("passphrase")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"main": "secret", "other": "other-token"}, tokens)
+}
+
+func TestMigrateFile(t *testing.T) {
+	type testcase struct {
+		fixture string
+		format  string
+		newPath string
+		want    map[string]interface{}
+	}
+
+	tt := map[string]testcase{
+		"yaml": {
+			fixture: "v0.yaml",
+			newPath: "v0.yaml",
+			want: map[string]interface{}{
+				KeyVersion:     FileVersion,
+				KeyAccount:     "12",
+				KeyAccessToken: "secret",
+				KeyBaseURL:     "https://api.example.com",
+				KeySandbox:     true,
+			},
+		},
+		"json": {
+			fixture: "v0.json",
+			newPath: "v0.json",
+			want: map[string]interface{}{
+				KeyVersion:     float64(FileVersion),
+				KeyAccount:     "12",
+				KeyAccessToken: "secret",
+				KeyBaseURL:     "https://api.example.com",
+			},
+		},
+		"json to yaml": {
+			fixture: "v0.json",
+			format:  "yaml",
+			newPath: "v0.yaml",
+			want: map[string]interface{}{
+				KeyVersion:     FileVersion,
+				KeyAccount:     "12",
+				KeyAccessToken: "secret",
+				KeyBaseURL:     "https://api.example.com",
+			},
+		},
+	}
+
+	for tn, tc := range tt {
+		t.Run(tn, func(t *testing.T) {
+			setConfigHome(t)
+
+			path := copyFixture(t, tc.fixture)
+
+			original, err := os.ReadFile(path)
+			require.NoError(t, err)
+
+			m, err := MigrateFile(path, "", tc.format)
+			require.NoError(t, err)
+			require.True(t, m.Migrated())
+			require.Equal(t, 0, m.From)
+			require.Equal(t, FileVersion, m.To)
+			require.Equal(t, filepath.Join(filepath.Dir(path), tc.newPath), m.NewPath)
+			require.Equal(t, tc.want, readSettings(t, m.NewPath))
+
+			backup, err := os.ReadFile(m.Backup)
+			require.NoError(t, err)
+			require.Equal(t, original, backup)
+
+			if m.NewPath != path {
+				require.NoFileExists(t, path)
+			}
+		})
+	}
+}
+
+func TestMigrateFileBackupSecrets(t *testing.T) {
+	type testcase struct {
+		fixture string
+		format  string
+		want    map[string]interface{}
+	}
+
+	tt := map[string]testcase{
+		"yaml": {
+			fixture: "v0.yaml",
+			want: map[string]interface{}{
+				KeyAccount: 12,
+				"base_url": "https://api.example.com",
+				KeySandbox: true,
+			},
+		},
+		"json to yaml": {
+			fixture: "v0.json",
+			format:  "yaml",
+			want: map[string]interface{}{
+				KeyAccount: float64(12),
+				"base_url": "https://api.example.com",
+			},
+		},
+	}
+
+	for tn, tc := range tt {
+		t.Run(tn, func(t *testing.T) {
+			setConfigHome(t)
+			t.Setenv(EnvPassphrase, "passphrase")
+
+			require.NoError(t, WriteCredentials(map[string]string{"other": "other-token"}, "passphrase"))
+
+			path := copyFixture(t, tc.fixture)
+
+			original, err := os.ReadFile(path)
+			require.NoError(t, err)
+			require.NoError(t, os.WriteFile(path+BackupSuffix, original, 0o600))
+
+			m, err := MigrateFile(path, "main", tc.format)
+			require.NoError(t, err)
+			require.NotContains(t, readSettings(t, m.NewPath), KeyAccessToken)
+
+			// the token moved to the credentials file is not kept in the
+			// backups of the file
+			backup, err := os.ReadFile(m.Backup)
+			require.NoError(t, err)
+			require.NotContains(t, string(backup), "secret")
+
+			v := viper.New()
+			v.SetConfigType(filepath.Ext(path)[1:])
+			require.NoError(t, v.ReadConfig(bytes.NewReader(backup)))
+			require.Equal(t, tc.want, v.AllSettings())
+
+			info, err := os.Stat(m.Backup)
+			require.NoError(t, err)
+			require.Equal(t, secretFilePerms, info.Mode().Perm())
+
+			require.NoFileExists(t, path+BackupSuffix)
+
+			tokens, err := ReadCredentials("passphrase")
+			require.NoError(t, err)
+			require.Equal(t, map[string]string{"main": "secret", "other": "other-token"}, tokens)
+		})
+	}
+}
+
+func TestMigrateFileRefused(t *testing.T) {
+	type testcase struct {
+		fixture string
+		format  string
+		setup   func(t *testing.T, path string)
+		errMsg  string
+	}
+
+	tt := map[string]testcase{
+		"newer version": {
+			fixture: "v2.yaml",
+			errMsg:  "newer than the supported version",
+		},
+		"existing target": {
+			fixture: "v0.yaml",
+			format:  "json",
+			setup: func(t *testing.T, path string) {
+				target := filepath.Join(filepath.Dir(path), "v0.json")
+				require.NoError(t, os.WriteFile(target, []byte("{}"), 0o600))
+			},
+			errMsg: "already exists",
+		},
+		"unknown format": {
+			fixture: "v0.yaml",
+			format:  "ini",
+			errMsg:  "invalid format",
+		},
+	}
+
+	for tn, tc := range tt {
+		t.Run(tn, func(t *testing.T) {
+			setConfigHome(t)
+
+			path := copyFixture(t, tc.fixture)
+			if tc.setup != nil {
+				tc.setup(t, path)
+			}
+
+			_, err := MigrateFile(path, "", tc.format)
+			require.ErrorContains(t, err, tc.errMsg)
+		})
+	}
+}
+
+func TestMigrateFileCurrentVersion(t *testing.T) {
+	setConfigHome(t)
+
+	path := copyFixture(t, "v1.yaml")
+
+	m, err := MigrateFile(path, "", "")
+	require.NoError(t, err)
+	require.False(t, m.Migrated())
+	require.Empty(t, m.Changes)
+}
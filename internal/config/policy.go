@@ -0,0 +1,290 @@
+// Copyright 2023 Edson Michaque
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	policyFileName = "policy"
+
+	policyKeyLocked          = "locked"
+	policyKeyForbidden       = "forbidden"
+	policyKeyPlaintextTokens = "plaintext-tokens"
+)
+
+// Policy is set by administrators in the system directory. Locked keys
+// have a fixed value that files, environment variables and flags cannot
+// override, forbidden values are refused and, unless plaintext tokens are
+// allowed, access tokens cannot be stored in configuration files.
+type Policy struct {
+	Path            string
+	Locked          map[string]interface{}
+	Forbidden       map[string][]string
+	PlaintextTokens bool
+}
+
+// PolicyError explains why a value was rejected by the policy
+type PolicyError struct {
+	Path   string
+	Key    string
+	Reason string
+}
+
+func (e PolicyError) Error() string {
+	return fmt.Sprintf("%s: %s by policy %s", e.Key, e.Reason, e.Path)
+}
+
+var (
+	policy    = Policy{PlaintextTokens: true}
+	policyErr error
+)
+
+// CurrentPolicy returns the policy read by Load, or the error reading it
+func CurrentPolicy() (Policy, error) {
+	return policy, policyErr
+}
+
+// Check returns a PolicyError when key cannot take value
+func (p Policy) Check(key string, value interface{}) error {
+	if locked, ok := p.Locked[key]; ok && fmt.Sprint(locked) != fmt.Sprint(value) {
+		return PolicyError{
+			Path:   p.Path,
+			Key:    key,
+			Reason: fmt.Sprintf("cannot be set to %q, it is locked to %q", fmt.Sprint(value), fmt.Sprint(locked)),
+		}
+	}
+
+	for _, forbidden := range p.Forbidden[key] {
+		if forbidden == fmt.Sprint(value) {
+			return PolicyError{
+				Path:   p.Path,
+				Key:    key,
+				Reason: fmt.Sprintf("value %q is forbidden", forbidden),
+			}
+		}
+	}
+
+	return nil
+}
+
+// CheckPlaintextToken returns a PolicyError when access tokens cannot be
+// stored in configuration files
+func (p Policy) CheckPlaintextToken() error {
+	if p.PlaintextTokens {
+		return nil
+	}
+
+	return PolicyError{
+		Path:   p.Path,
+		Key:    KeyAccessToken,
+		Reason: "cannot be stored in clear text",
+	}
+}
+
+// CheckSettings returns a PolicyError for the first value of settings the
+// policy refuses, including an access token when plaintext tokens are not
+// allowed
+func (p Policy) CheckSettings(settings map[string]interface{}) error {
+	for _, key := range schema {
+		value, ok := settings[key.Name]
+		if !ok {
+			continue
+		}
+
+		if err := p.Check(key.Name, value); err != nil {
+			return err
+		}
+	}
+
+	if _, ok := settings[KeyAccessToken]; ok {
+		return p.CheckPlaintextToken()
+	}
+
+	return nil
+}
+
+// CheckPolicy checks settings against the policy read by Load
+func CheckPolicy(settings map[string]interface{}) error {
+	if policyErr != nil {
+		return policyErr
+	}
+
+	return policy.CheckSettings(settings)
+}
+
+// CheckStorePolicy checks settings about to be handed to StoreToken and
+// written to a file against the policy read by Load, so nothing is stored
+// when they are refused. An access token kept by a credential helper or
+// the encrypted credentials file is not subject to the plaintext tokens
+// rule, as it is not written to the file.
+func CheckStorePolicy(settings map[string]interface{}) error {
+	if policyErr != nil {
+		return policyErr
+	}
+
+	stored, err := storesToken(settings)
+	if err != nil {
+		return err
+	}
+
+	p := policy
+	if stored {
+		p.PlaintextTokens = true
+	}
+
+	return p.CheckSettings(settings)
+}
+
+// IsLocked reports whether key is locked by the policy
+func (p Policy) IsLocked(key string) bool {
+	_, ok := p.Locked[key]
+
+	return ok
+}
+
+// readPolicy reads the policy file in dir, if any
+func readPolicy(dir string) (Policy, error) {
+	p := Policy{PlaintextTokens: true}
+
+	if dir == "" {
+		return p, nil
+	}
+
+	path, err := findFile(dir, policyFileName)
+	if err != nil || path == "" {
+		return p, err
+	}
+
+	v := viper.New()
+	v.SetConfigFile(path)
+
+	if err := v.ReadInConfig(); err != nil {
+		return p, fmt.Errorf("%s: %w", path, err)
+	}
+
+	p.Path = path
+	p.Locked = make(map[string]interface{})
+	p.Forbidden = make(map[string][]string)
+
+	if v.IsSet(policyKeyPlaintextTokens) {
+		p.PlaintextTokens = v.GetBool(policyKeyPlaintextTokens)
+	}
+
+	for name, value := range v.GetStringMap(policyKeyLocked) {
+		key, err := LookupKey(name)
+		if err != nil {
+			return p, fmt.Errorf("%s: %w", path, err)
+		}
+
+		parsed, err := key.Parse(fmt.Sprint(value))
+		if err != nil {
+			return p, fmt.Errorf("%s: %w", path, err)
+		}
+
+		p.Locked[key.Name] = parsed
+	}
+
+	for name := range v.GetStringMap(policyKeyForbidden) {
+		key, err := LookupKey(name)
+		if err != nil {
+			return p, fmt.Errorf("%s: %w", path, err)
+		}
+
+		p.Forbidden[key.Name] = v.GetStringSlice(policyKeyForbidden + "." + name)
+	}
+
+	return p, nil
+}
+
+// applyPolicy forces the locked values into viper, warning about the
+// files and environment variables they override
+func applyPolicy() {
+	keys := make([]string, 0, len(policy.Locked))
+	for key := range policy.Locked {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, name := range keys {
+		value := policy.Locked[name]
+
+		key, _ := LookupKey(name)
+
+		for _, layer := range layers {
+			if layer.Source == SourceSystem || !hasKey(layer.Values, name) {
+				continue
+			}
+
+			if err := policy.Check(name, layer.Values[name]); err != nil {
+				warnFunc(fmt.Sprintf("ignoring %s: %s", layer.Path, err))
+			}
+		}
+
+		for _, env := range key.Env {
+			if s := os.Getenv(env); s != "" {
+				if err := policy.Check(name, s); err != nil {
+					warnFunc(fmt.Sprintf("ignoring %s: %s", env, err))
+				}
+			}
+		}
+
+		viper.Set(name, value)
+	}
+}
+
+// checkPolicy refuses resolved values that differ from locked ones or are
+// forbidden by the policy, and access tokens stored in files when
+// plaintext tokens are not allowed. It fails when the policy could not be
+// read.
+func (c Config) checkPolicy() error {
+	if policyErr != nil {
+		return policyErr
+	}
+
+	settings := c.allSettings()
+
+	for key := range policy.Locked {
+		if err := policy.Check(key, settings[key]); err != nil {
+			return err
+		}
+	}
+
+	for key := range policy.Forbidden {
+		if err := policy.Check(key, settings[key]); err != nil {
+			return err
+		}
+	}
+
+	if policy.PlaintextTokens {
+		return nil
+	}
+
+	for _, layer := range layers {
+		if hasKey(layer.Values, KeyAccessToken) {
+			return fmt.Errorf("%s: %w", layer.Path, policy.CheckPlaintextToken())
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,291 @@
+// Copyright 2023 Edson Michaque
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+// writeFile writes content to name in dir, creating dir
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+
+	require.NoError(t, os.MkdirAll(dir, 0o700))
+
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	return path
+}
+
+func TestReadPolicy(t *testing.T) {
+	type testcase struct {
+		content string
+		want    Policy
+		errMsg  string
+	}
+
+	tt := map[string]testcase{
+		"no policy file": {
+			want: Policy{PlaintextTokens: true},
+		},
+		"locked, forbidden and plaintext tokens": {
+			content: "locked:\n  base-url: https://api.internal.example.com\n  sandbox: true\n" +
+				"forbidden:\n  env: [prod, staging]\nplaintext-tokens: false\n",
+			want: Policy{
+				Locked: map[string]interface{}{
+					KeyBaseURL: "https://api.internal.example.com",
+					KeySandbox: true,
+				},
+				Forbidden: map[string][]string{
+					KeyEnv: {"prod", "staging"},
+				},
+				PlaintextTokens: false,
+			},
+		},
+		"unknown locked key": {
+			content: "locked:\n  endpoint: https://api.internal.example.com\n",
+			errMsg:  `unknown configuration key "endpoint"`,
+		},
+		"invalid locked value": {
+			content: "locked:\n  sandbox: maybe\n",
+			errMsg:  "sandbox",
+		},
+		"unknown forbidden key": {
+			content: "forbidden:\n  endpoint: [x]\n",
+			errMsg:  `unknown configuration key "endpoint"`,
+		},
+	}
+
+	for tn, tc := range tt {
+		t.Run(tn, func(t *testing.T) {
+			dir := t.TempDir()
+
+			var path string
+			if tc.content != "" {
+				path = writeFile(t, dir, "policy.yaml", tc.content)
+			}
+
+			got, err := readPolicy(dir)
+			if tc.errMsg != "" {
+				require.ErrorContains(t, err, tc.errMsg)
+
+				return
+			}
+
+			require.NoError(t, err)
+
+			tc.want.Path = path
+			if path == "" {
+				require.Equal(t, tc.want, got)
+
+				return
+			}
+
+			require.Equal(t, tc.want.Path, got.Path)
+			require.Equal(t, tc.want.Locked, got.Locked)
+			require.Equal(t, tc.want.Forbidden, got.Forbidden)
+			require.Equal(t, tc.want.PlaintextTokens, got.PlaintextTokens)
+		})
+	}
+}
+
+func TestPolicyCheck(t *testing.T) {
+	p := Policy{
+		Path:   "/etc/template/policy.yaml",
+		Locked: map[string]interface{}{KeyBaseURL: "https://api.internal.example.com"},
+		Forbidden: map[string][]string{
+			KeySandbox: {"false"},
+		},
+		PlaintextTokens: false,
+	}
+
+	type testcase struct {
+		settings map[string]interface{}
+		errMsg   string
+	}
+
+	tt := map[string]testcase{
+		"locked value": {
+			settings: map[string]interface{}{KeyBaseURL: "https://api.internal.example.com"},
+		},
+		"other value of a locked key": {
+			settings: map[string]interface{}{KeyBaseURL: "https://evil.example.com"},
+			errMsg: `base-url: cannot be set to "https://evil.example.com", it is locked to ` +
+				`"https://api.internal.example.com" by policy /etc/template/policy.yaml`,
+		},
+		"forbidden value": {
+			settings: map[string]interface{}{KeySandbox: false},
+			errMsg:   `sandbox: value "false" is forbidden by policy /etc/template/policy.yaml`,
+		},
+		"allowed value": {
+			settings: map[string]interface{}{KeySandbox: true, KeyAccount: "1"},
+		},
+		"plaintext token": {
+			settings: map[string]interface{}{KeyAccessToken: "secret"},
+			errMsg:   "access-token: cannot be stored in clear text by policy /etc/template/policy.yaml",
+		},
+	}
+
+	for tn, tc := range tt {
+		t.Run(tn, func(t *testing.T) {
+			err := p.CheckSettings(tc.settings)
+			if tc.errMsg == "" {
+				require.NoError(t, err)
+
+				return
+			}
+
+			require.EqualError(t, err, tc.errMsg)
+			require.ErrorAs(t, err, &PolicyError{})
+		})
+	}
+
+	require.NoError(t, Policy{PlaintextTokens: true}.CheckPlaintextToken())
+}
+
+func TestLoadPolicy(t *testing.T) {
+	const (
+		lockedURL = "https://api.internal.example.com"
+		policy    = "locked:\n  base-url: " + lockedURL + "\n"
+	)
+
+	type testcase struct {
+		policy   string
+		profile  string
+		project  string
+		env      map[string]string
+		baseURL  string
+		warnings int
+		loadErr  string
+		initErr  string
+	}
+
+	tt := map[string]testcase{
+		"locked key overrides the profile": {
+			policy:   policy,
+			profile:  "version: 1\naccount: \"1\"\nbase-url: https://profile.example.com\n",
+			baseURL:  lockedURL,
+			warnings: 1,
+		},
+		"locked key overrides the environment": {
+			policy:   policy,
+			profile:  "version: 1\naccount: \"1\"\n",
+			env:      map[string]string{"TEMPLATE_BASE_URL": "https://env.example.com"},
+			baseURL:  lockedURL,
+			warnings: 1,
+		},
+		"locked key applied when a file cannot be loaded": {
+			policy:   policy,
+			profile:  "version: 1\naccount: \"1\"\n",
+			project:  "account: [\n",
+			env:      map[string]string{"TEMPLATE_BASE_URL": "https://evil.example.com"},
+			baseURL:  lockedURL,
+			warnings: 1,
+			loadErr:  ".template.yaml",
+			initErr:  ".template.yaml",
+		},
+		"forbidden value": {
+			policy:  "forbidden:\n  sandbox: [false]\n",
+			profile: "version: 1\naccount: \"1\"\n",
+			initErr: `sandbox: value "false" is forbidden`,
+		},
+		"plaintext token in a profile": {
+			policy:  "plaintext-tokens: false\n",
+			profile: "version: 1\naccount: \"1\"\naccess-token: secret\n",
+			initErr: "access-token: cannot be stored in clear text",
+		},
+		"plaintext token from the environment": {
+			policy:  "plaintext-tokens: false\n",
+			profile: "version: 1\naccount: \"1\"\n",
+			env:     map[string]string{"TEMPLATE_ACCESS_TOKEN": "secret"},
+		},
+		"invalid policy": {
+			policy:  "locked:\n  endpoint: x\n",
+			profile: "version: 1\naccount: \"1\"\n",
+			loadErr: `unknown configuration key "endpoint"`,
+			initErr: `unknown configuration key "endpoint"`,
+		},
+	}
+
+	for tn, tc := range tt {
+		t.Run(tn, func(t *testing.T) {
+			home := setConfigHome(t)
+			systemDir := t.TempDir()
+			workDir := t.TempDir()
+
+			for _, key := range schema {
+				for _, env := range key.Env {
+					t.Setenv(env, "")
+				}
+			}
+
+			for env, value := range tc.env {
+				t.Setenv(env, value)
+			}
+
+			writeFile(t, systemDir, "policy.yaml", tc.policy)
+			writeFile(t, filepath.Join(home, appName), "main.yaml", tc.profile)
+
+			if tc.project != "" {
+				writeFile(t, workDir, projectFileName+".yaml", tc.project)
+			}
+
+			var warnings []string
+
+			SetWarnFunc(func(msg string) {
+				warnings = append(warnings, msg)
+			})
+
+			t.Cleanup(func() {
+				viper.Reset()
+				SetWarnFunc(func(string) {})
+			})
+
+			viper.Reset()
+
+			err := Load(LoadOpts{
+				Profile:   DefaultProfile,
+				SystemDir: systemDir,
+				WorkDir:   workDir,
+			})
+			if tc.loadErr != "" {
+				require.ErrorContains(t, err, tc.loadErr)
+			} else {
+				require.NoError(t, err)
+			}
+
+			require.Len(t, warnings, tc.warnings)
+
+			if tc.baseURL != "" {
+				require.Equal(t, tc.baseURL, viper.GetString(KeyBaseURL))
+			}
+
+			_, err = Init(false)
+			if tc.initErr != "" {
+				require.ErrorContains(t, err, tc.initErr)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
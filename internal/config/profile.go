@@ -0,0 +1,574 @@
+// Copyright 2023 Edson Michaque
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	appName            = "template"
+	currentProfileFile = "current-profile"
+	DefaultProfile     = "main"
+
+	// KeyExtends is the key of a configuration file naming the profile it
+	// inherits its values from
+	KeyExtends = "extends"
+)
+
+// Formats lists the supported profile file formats
+var Formats = []string{"json", "yaml", "yml", "toml"}
+
+var ErrProfileNotFound = errors.New("profile not found")
+
+// Profile is a profile file in the user configuration directory
+type Profile struct {
+	Name   string
+	Format string
+	Path   string
+}
+
+// Dir returns the user configuration directory
+func Dir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, appName), nil
+}
+
+// ProfilePath returns the path of the profile file name with format ext
+func ProfilePath(name, ext string) (string, error) {
+	if err := ValidateProfileName(name); err != nil {
+		return "", err
+	}
+
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, fmt.Sprintf("%s.%s", name, strings.ToLower(ext))), nil
+}
+
+// ValidateProfileName rejects names that cannot be used as file names
+func ValidateProfileName(name string) error {
+	if name == "" {
+		return errors.New("profile name is required")
+	}
+
+	if strings.HasPrefix(name, ".") || strings.ContainsAny(name, `/\`) || name == currentProfileFile {
+		return fmt.Errorf("invalid profile name %q", name)
+	}
+
+	return nil
+}
+
+// Profiles lists the profile files in the user configuration directory
+func Profiles() ([]Profile, error) {
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	profiles := make([]Profile, 0, len(entries))
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		ext := strings.TrimPrefix(filepath.Ext(entry.Name()), ".")
+		if !isFormat(ext) {
+			continue
+		}
+
+		name := strings.TrimSuffix(entry.Name(), "."+ext)
+		if ValidateProfileName(name) != nil {
+			continue
+		}
+
+		profiles = append(profiles, Profile{
+			Name:   name,
+			Format: ext,
+			Path:   filepath.Join(dir, entry.Name()),
+		})
+	}
+
+	sort.Slice(profiles, func(i, j int) bool {
+		return profiles[i].Name < profiles[j].Name
+	})
+
+	return profiles, nil
+}
+
+// FindProfile returns the profile file of name
+func FindProfile(name string) (*Profile, error) {
+	if err := ValidateProfileName(name); err != nil {
+		return nil, err
+	}
+
+	profiles, err := Profiles()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range profiles {
+		if profiles[i].Name == name {
+			return &profiles[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrProfileNotFound, name)
+}
+
+// CurrentProfile returns the profile selected with SetCurrentProfile, or
+// the default profile
+func CurrentProfile() (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, currentProfileFile))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return DefaultProfile, nil
+		}
+
+		return "", err
+	}
+
+	name := strings.TrimSpace(string(data))
+	if name == "" {
+		return DefaultProfile, nil
+	}
+
+	return name, nil
+}
+
+// SetCurrentProfile persists name as the current profile
+func SetCurrentProfile(name string) error {
+	if err := ValidateProfileName(name); err != nil {
+		return err
+	}
+
+	dir, err := Dir()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(dir, currentProfileFile), []byte(name+"\n"), 0o600)
+}
+
+// ClearCurrentProfile resets the current profile to the default profile
+func ClearCurrentProfile() error {
+	dir, err := Dir()
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(filepath.Join(dir, currentProfileFile))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+func isFormat(ext string) bool {
+	for _, format := range Formats {
+		if ext == format {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parentLayers returns the layers of the profiles extended by the file at
+// path with values, the farthest ancestor first
+func parentLayers(path string, values map[string]interface{}) ([]Layer, error) {
+	var parents []Layer
+
+	seen := []string{path}
+	chain := []string{strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))}
+
+	for hasKey(values, KeyExtends) {
+		name, ok := values[KeyExtends].(string)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("%s: %s must be a profile name", path, KeyExtends)
+		}
+
+		chain = append(chain, name)
+
+		p, err := FindProfile(name)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", path, KeyExtends, err)
+		}
+
+		for _, s := range seen {
+			if s == p.Path {
+				return nil, fmt.Errorf("profile inheritance cycle: %s", strings.Join(chain, " -> "))
+			}
+		}
+
+		layer, err := readLayer(SourceProfile, p.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		parents = append([]Layer{*layer}, parents...)
+		seen = append(seen, p.Path)
+		path, values = p.Path, layer.Values
+	}
+
+	return parents, nil
+}
+
+// ReadProfile reads the values stored in the profile file of name
+func ReadProfile(name string) ([]Value, error) {
+	p, err := FindProfile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	v := viper.New()
+	v.SetConfigFile(p.Path)
+
+	if err := v.ReadInConfig(); err != nil {
+		return nil, err
+	}
+
+	return Values(v), nil
+}
+
+// ProfileSettings returns every key stored in the profile file of name,
+// upgraded to FileVersion, or none when it does not exist. The keys of the
+// schema are converted to their type, other keys such as extends or
+// environments are kept as they are.
+func ProfileSettings(name string) (map[string]interface{}, error) {
+	p, err := FindProfile(name)
+	if err != nil {
+		if errors.Is(err, ErrProfileNotFound) {
+			return map[string]interface{}{}, nil
+		}
+
+		return nil, err
+	}
+
+	v := viper.New()
+	v.SetConfigFile(p.Path)
+
+	if err := v.ReadInConfig(); err != nil {
+		return nil, err
+	}
+
+	settings := v.AllSettings()
+
+	version, err := FileVersionOf(settings)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", p.Path, err)
+	}
+
+	if version > FileVersion {
+		return nil, newerVersionError(p.Path, version)
+	}
+
+	for _, mig := range migrations {
+		if mig.from < version {
+			continue
+		}
+
+		// without a profile, the access token stays in settings
+		if _, err := mig.migrate("", settings); err != nil {
+			return nil, fmt.Errorf("%s: %w", p.Path, err)
+		}
+	}
+
+	for _, key := range schema {
+		value, ok := settings[key.Name]
+		if !ok {
+			continue
+		}
+
+		if settings[key.Name], err = key.Parse(fmt.Sprint(value)); err != nil {
+			return nil, fmt.Errorf("%s: %w", p.Path, err)
+		}
+	}
+
+	return settings, nil
+}
+
+// UpdateProfile sets and unsets keys in the profile file of name,
+// creating it with format when it does not exist. It returns the path of
+// the profile file.
+func UpdateProfile(name, format string, set map[string]interface{}, unset []string) (string, error) {
+	var path string
+
+	p, err := FindProfile(name)
+
+	switch {
+	case err == nil:
+		path = p.Path
+	case errors.Is(err, ErrProfileNotFound):
+		if !isFormat(format) {
+			return "", fmt.Errorf("invalid format %q, expected one of: %s", format, strings.Join(Formats, ", "))
+		}
+
+		path, err = ProfilePath(name, format)
+		if err != nil {
+			return "", err
+		}
+	default:
+		return "", err
+	}
+
+	return path, UpdateFile(path, set, unset)
+}
+
+// UpdateFile sets and unsets keys in the configuration file at path,
+// keeping every other key. The file is created at FileVersion when it does
+// not exist, and files written by a newer release are refused.
+func UpdateFile(path string, set map[string]interface{}, unset []string) error {
+	settings := make(map[string]interface{})
+
+	_, err := os.Stat(path)
+
+	switch {
+	case err == nil:
+		v := viper.New()
+		v.SetConfigFile(path)
+
+		if err := v.ReadInConfig(); err != nil {
+			return err
+		}
+
+		settings = v.AllSettings()
+
+		version, err := FileVersionOf(settings)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+
+		if version > FileVersion {
+			return newerVersionError(path, version)
+		}
+	case errors.Is(err, fs.ErrNotExist):
+		settings[KeyVersion] = FileVersion
+	default:
+		return err
+	}
+
+	for _, key := range unset {
+		delete(settings, key)
+	}
+
+	for key, value := range set {
+		settings[key] = value
+	}
+
+	return WriteFile(path, settings)
+}
+
+// RenameProfile renames the profile file of name, keeping its format and
+// following the current profile. Its access token is moved along in the
+// credential helper and the encrypted credentials file, and the profiles
+// extending it are changed to extend newName.
+func RenameProfile(name, newName string) error {
+	p, err := FindProfile(name)
+	if err != nil {
+		return err
+	}
+
+	dst, err := newProfilePath(newName, p.Format)
+	if err != nil {
+		return err
+	}
+
+	children, err := extendingProfiles(name)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Rename(p.Path, dst); err != nil {
+		return err
+	}
+
+	if err := renameBackup(p.Path, dst); err != nil {
+		return err
+	}
+
+	for _, child := range children {
+		if err := UpdateFile(child.Path, map[string]interface{}{KeyExtends: newName}, nil); err != nil {
+			return err
+		}
+	}
+
+	if err := transferToken(dst, name, newName, false); err != nil {
+		return err
+	}
+
+	current, err := CurrentProfile()
+	if err != nil {
+		return err
+	}
+
+	if current == name {
+		return SetCurrentProfile(newName)
+	}
+
+	return nil
+}
+
+// CopyProfile copies the profile file of name into a new profile, along
+// with its access token in the credential helper and the encrypted
+// credentials file
+func CopyProfile(name, newName string) error {
+	p, err := FindProfile(name)
+	if err != nil {
+		return err
+	}
+
+	dst, err := newProfilePath(newName, p.Format)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(p.Path)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(dst, data, 0o600); err != nil {
+		return err
+	}
+
+	return transferToken(dst, name, newName, true)
+}
+
+// DeleteProfile removes the profile file of name and its access token,
+// resetting the current profile when it is the one removed. Profiles
+// extended by other profiles are not removed.
+func DeleteProfile(name string) error {
+	p, err := FindProfile(name)
+	if err != nil {
+		return err
+	}
+
+	children, err := extendingProfiles(name)
+	if err != nil {
+		return err
+	}
+
+	if len(children) > 0 {
+		names := make([]string, 0, len(children))
+		for _, child := range children {
+			names = append(names, child.Name)
+		}
+
+		return fmt.Errorf(
+			"profile %q is extended by %s, change their %s key first",
+			name,
+			strings.Join(names, ", "),
+			KeyExtends,
+		)
+	}
+
+	if err := transferToken(p.Path, name, "", false); err != nil {
+		return err
+	}
+
+	if err := os.Remove(p.Path); err != nil {
+		return err
+	}
+
+	if err := removeBackup(p.Path); err != nil {
+		return err
+	}
+
+	current, err := CurrentProfile()
+	if err != nil {
+		return err
+	}
+
+	if current == name {
+		return ClearCurrentProfile()
+	}
+
+	return nil
+}
+
+// extendingProfiles returns the profiles whose file extends profile name
+func extendingProfiles(name string) ([]Profile, error) {
+	profiles, err := Profiles()
+	if err != nil {
+		return nil, err
+	}
+
+	var children []Profile
+
+	for _, p := range profiles {
+		if p.Name == name {
+			continue
+		}
+
+		layer, err := readLayer(SourceProfile, p.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		if parent, _ := layer.Values[KeyExtends].(string); parent == name {
+			children = append(children, p)
+		}
+	}
+
+	return children, nil
+}
+
+// newProfilePath returns the path of a profile that must not exist yet
+func newProfilePath(name, ext string) (string, error) {
+	if _, err := FindProfile(name); err == nil {
+		return "", fmt.Errorf("profile %q already exists", name)
+	} else if !errors.Is(err, ErrProfileNotFound) {
+		return "", err
+	}
+
+	return ProfilePath(name, ext)
+}
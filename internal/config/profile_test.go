@@ -0,0 +1,90 @@
+// Copyright 2023 Edson Michaque
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeleteProfileExtended(t *testing.T) {
+	type testcase struct {
+		name   string
+		errMsg string
+	}
+
+	tt := map[string]testcase{
+		"extended by other profiles": {
+			name:   "base",
+			errMsg: `profile "base" is extended by dev, staging, change their extends key first`,
+		},
+		"extending another profile": {
+			name: "dev",
+		},
+	}
+
+	for tn, tc := range tt {
+		t.Run(tn, func(t *testing.T) {
+			dir := filepath.Join(setConfigHome(t), appName)
+			writeFile(t, dir, "base.yaml", "account: \"1010\"\n")
+			writeFile(t, dir, "dev.yaml", "extends: base\nsandbox: true\n")
+			writeFile(t, dir, "staging.json", `{"extends": "base"}`)
+
+			err := DeleteProfile(tc.name)
+			if tc.errMsg != "" {
+				require.EqualError(t, err, tc.errMsg)
+				require.FileExists(t, filepath.Join(dir, tc.name+".yaml"))
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.NoFileExists(t, filepath.Join(dir, tc.name+".yaml"))
+		})
+	}
+}
+
+func TestRenameProfileExtended(t *testing.T) {
+	dir := filepath.Join(setConfigHome(t), appName)
+	writeFile(t, dir, "base.yaml", "account: \"1010\"\n")
+	writeFile(t, dir, "dev.yaml", "extends: base\nsandbox: true\n")
+	writeFile(t, dir, "other.yaml", "account: \"2020\"\n")
+
+	require.NoError(t, RenameProfile("base", "team"))
+
+	for name, parent := range map[string]interface{}{"dev": "team", "other": nil} {
+		p, err := FindProfile(name)
+		require.NoError(t, err)
+
+		layer, err := readLayer(SourceProfile, p.Path)
+		require.NoError(t, err)
+		require.Equal(t, parent, layer.Values[KeyExtends])
+	}
+
+	p, err := FindProfile("dev")
+	require.NoError(t, err)
+
+	layer, err := readLayer(SourceProfile, p.Path)
+	require.NoError(t, err)
+
+	layers, err := parentLayers(p.Path, layer.Values)
+	require.NoError(t, err)
+	require.NotEmpty(t, layers)
+	require.Equal(t, "1010", layers[0].Values[KeyAccount])
+}
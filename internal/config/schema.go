@@ -0,0 +1,251 @@
+// Copyright 2023 Edson Michaque
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+const envPrefix = "TEMPLATE"
+
+// Type is the type of the value of a key
+type Type string
+
+const (
+	TypeString = Type("string")
+	TypeBool   = Type("bool")
+)
+
+const (
+	KeyAccessToken      = "access-token"
+	KeyAccount          = "account"
+	KeyBaseURL          = "base-url"
+	KeyCredentialHelper = "credential-helper"
+	KeyEnv              = "env"
+	KeySandbox          = "sandbox"
+)
+
+// Key describes a configuration key
+type Key struct {
+	Name        string
+	Type        Type
+	Default     interface{}
+	Description string
+	// Required keys must be set for commands calling the API
+	Required bool
+	// Sensitive values are masked in output
+	Sensitive bool
+	// Prompt asks for the key in config init
+	Prompt bool
+	// Env lists the environment variables allowed to set the key
+	Env []string
+	// Options returns the values allowed for the key, if limited
+	Options func() []string
+	// Validate checks a value already converted to Type
+	Validate func(value interface{}) error
+}
+
+// schema lists every configuration key, in the order they are prompted
+// and printed
+var schema = []Key{
+	{
+		Name:        KeyAccount,
+		Type:        TypeString,
+		Description: "Account ID",
+		Required:    true,
+		Prompt:      true,
+		Env:         []string{envName(KeyAccount)},
+		Validate:    validateAccount,
+	},
+	{
+		Name:        KeyAccessToken,
+		Type:        TypeString,
+		Description: "Access token",
+		Required:    true,
+		Sensitive:   true,
+		Prompt:      true,
+		Env:         []string{envName(KeyAccessToken)},
+	},
+	{
+		Name:        KeyEnv,
+		Type:        TypeString,
+		Description: "Environment",
+		Prompt:      true,
+		Env:         []string{envName(KeyEnv)},
+		Options:     EnvironmentNames,
+		Validate:    validateEnvironmentName,
+	},
+	{
+		Name:        KeyBaseURL,
+		Type:        TypeString,
+		Description: "Base URL of the API, replacing the one of the environment",
+		Prompt:      true,
+		Env:         []string{envName(KeyBaseURL)},
+		Validate:    validateURL,
+	},
+	{
+		Name:        KeyCredentialHelper,
+		Type:        TypeString,
+		Description: "Command storing the access token",
+		Env:         []string{envName(KeyCredentialHelper)},
+	},
+	{
+		Name:        KeySandbox,
+		Type:        TypeBool,
+		Default:     false,
+		Description: "Use the sandbox environment, unless env is set",
+		Env:         []string{envName(KeySandbox)},
+	},
+}
+
+// Keys returns every configuration key
+func Keys() []Key {
+	keys := make([]Key, len(schema))
+	copy(keys, schema)
+
+	return keys
+}
+
+// KeyNames returns the name of every configuration key
+func KeyNames() []string {
+	names := make([]string, 0, len(schema))
+	for _, key := range schema {
+		names = append(names, key.Name)
+	}
+
+	return names
+}
+
+// LookupKey returns the key called name
+func LookupKey(name string) (Key, error) {
+	for _, key := range schema {
+		if key.Name == name {
+			return key, nil
+		}
+	}
+
+	return Key{}, fmt.Errorf("unknown configuration key %q", name)
+}
+
+// Parse converts value to the type of the key and validates it
+func (k Key) Parse(value string) (interface{}, error) {
+	var (
+		v   interface{}
+		err error
+	)
+
+	switch k.Type {
+	case TypeBool:
+		v, err = strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: expected a boolean, got %q", k.Name, value)
+		}
+	default:
+		v = value
+	}
+
+	if err := k.Check(v); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// Check validates value, which must already have the type of the key
+func (k Key) Check(value interface{}) error {
+	if k.Validate == nil {
+		return nil
+	}
+
+	if err := k.Validate(value); err != nil {
+		return fmt.Errorf("%s: %w", k.Name, err)
+	}
+
+	return nil
+}
+
+// Get returns the value of the key as resolved by v
+func (k Key) Get(v *viper.Viper) interface{} {
+	switch k.Type {
+	case TypeBool:
+		return v.GetBool(k.Name)
+	default:
+		return v.GetString(k.Name)
+	}
+}
+
+// Value is the value of a key
+type Value struct {
+	Key   Key
+	Value interface{}
+}
+
+// Values returns the value of every key as resolved by v
+func Values(v *viper.Viper) []Value {
+	values := make([]Value, 0, len(schema))
+	for _, key := range schema {
+		values = append(values, Value{Key: key, Value: key.Get(v)})
+	}
+
+	return values
+}
+
+// envName returns the environment variable named after key
+func envName(key string) string {
+	return envPrefix + "_" + strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+}
+
+func validateAccount(value interface{}) error {
+	s, _ := value.(string)
+	if s == "" {
+		return nil
+	}
+
+	if _, err := strconv.ParseUint(s, 10, 64); err != nil {
+		return fmt.Errorf("account id must be numeric, got %q", s)
+	}
+
+	return nil
+}
+
+func validateURL(value interface{}) error {
+	s, _ := value.(string)
+	if s == "" {
+		return nil
+	}
+
+	u, err := url.Parse(s)
+	if err != nil {
+		return err
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.New("url scheme must be http or https")
+	}
+
+	if u.Host == "" {
+		return errors.New("url host is required")
+	}
+
+	return nil
+}
@@ -0,0 +1,281 @@
+// Copyright 2023 Edson Michaque
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/viper"
+)
+
+const (
+	// BackupSuffix is appended to the path of a configuration file to name
+	// the copy of its previous content
+	BackupSuffix = ".bak"
+
+	filePerms       fs.FileMode = 0o644
+	secretFilePerms fs.FileMode = 0o600
+)
+
+// WriteFile replaces the configuration file at path with settings, in the
+// format of its extension. See ReplaceFile.
+func WriteFile(path string, settings map[string]interface{}) error {
+	return writeAtomic(path, filePermsFor(path, settings), backupFor(settings), func(f *os.File) error {
+		return writeSettings(f.Name(), settings)
+	})
+}
+
+// ReplaceFile replaces the configuration file at path with data. The new
+// content is written to a temporary file renamed over path, so a crash
+// never leaves a truncated file, and the previous content is kept in a
+// file with the BackupSuffix. Files holding a sensitive key are only
+// readable by their owner, and their secrets are not kept in the backup
+// once the new content drops them.
+func ReplaceFile(path string, data []byte) error {
+	settings, err := parseSettings(path, data)
+	if err != nil {
+		return err
+	}
+
+	return writeAtomic(path, filePermsFor(path, settings), backupFor(settings), func(f *os.File) error {
+		_, err := f.Write(data)
+
+		return err
+	})
+}
+
+// parseSettings parses data in the format of the extension of path
+func parseSettings(path string, data []byte) (map[string]interface{}, error) {
+	v := viper.New()
+	v.SetConfigType(strings.TrimPrefix(filepath.Ext(path), "."))
+
+	if err := v.ReadConfig(bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return v.AllSettings(), nil
+}
+
+// writeSettings writes settings to path, in the format of its extension
+func writeSettings(path string, settings map[string]interface{}) error {
+	v := viper.New()
+	for key, value := range settings {
+		v.Set(key, value)
+	}
+
+	return v.WriteConfigAs(path)
+}
+
+// encodeSettings returns settings encoded in format. Viper only writes
+// files named after their format, so they are written in memory.
+func encodeSettings(format string, settings map[string]interface{}) ([]byte, error) {
+	fs := afero.NewMemMapFs()
+
+	v := viper.New()
+	v.SetFs(fs)
+
+	for key, value := range settings {
+		v.Set(key, value)
+	}
+
+	name := "settings." + format
+	if err := v.WriteConfigAs(name); err != nil {
+		return nil, err
+	}
+
+	return afero.ReadFile(fs, name)
+}
+
+// withoutSecrets returns a copy of settings without their sensitive keys,
+// including the names files at version 0 gave them
+func withoutSecrets(settings map[string]interface{}) map[string]interface{} {
+	secret := make(map[string]bool)
+
+	for _, key := range schema {
+		secret[key.Name] = key.Sensitive
+	}
+
+	for _, rename := range renamedKeys {
+		secret[rename[0]] = secret[rename[1]]
+	}
+
+	stripped := make(map[string]interface{}, len(settings))
+
+	for key, value := range settings {
+		if !secret[key] {
+			stripped[key] = value
+		}
+	}
+
+	return stripped
+}
+
+// writeAtomic calls write with a temporary file next to path, then renames
+// it over path, first calling backup, if any, with path
+func writeAtomic(path string, perm fs.FileMode, backup func(string) error, write func(*os.File) error) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+
+	ext := filepath.Ext(path)
+
+	tmp, err := os.CreateTemp(dir, "."+strings.TrimSuffix(filepath.Base(path), ext)+"-*"+ext)
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	if err := writeTemp(tmp, perm, write); err != nil {
+		return err
+	}
+
+	if backup != nil {
+		if err := backup(path); err != nil {
+			return err
+		}
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func writeTemp(tmp *os.File, perm fs.FileMode, write func(*os.File) error) error {
+	defer tmp.Close()
+
+	if err := tmp.Chmod(perm); err != nil {
+		return err
+	}
+
+	if err := write(tmp); err != nil {
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+
+	return tmp.Close()
+}
+
+// backupFor returns the backup of a file about to hold settings. Its
+// current content is copied to the backup, replacing the previous one,
+// unless it holds secrets that settings drop or plaintext tokens are not
+// allowed. The backup is then removed, so secrets moved out of a file, by
+// config encrypt for instance, do not stay next to it.
+func backupFor(settings map[string]interface{}) func(string) error {
+	return func(path string) error {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+
+			return err
+		}
+
+		current, err := parseSettings(path, data)
+		if err == nil && hasSecrets(current) && (!hasSecrets(settings) || !policy.PlaintextTokens) {
+			return removeBackup(path)
+		}
+
+		return backupFile(path, data)
+	}
+}
+
+// backupFile writes data to the backup of the file at path. Backups are
+// only readable by their owner as they may hold secrets.
+func backupFile(path string, data []byte) error {
+	return writeAtomic(path+BackupSuffix, secretFilePerms, nil, func(f *os.File) error {
+		_, err := f.Write(data)
+
+		return err
+	})
+}
+
+// removeBackup removes the backup of the file at path, if any
+func removeBackup(path string) error {
+	if err := os.Remove(path + BackupSuffix); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+// renameBackup moves the backup of the file at path along with it to
+// newPath, if any
+func renameBackup(path, newPath string) error {
+	if err := os.Rename(path+BackupSuffix, newPath+BackupSuffix); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+// filePermsFor returns the permissions of the file at path once it holds
+// settings: those of the current file, restricted to its owner when
+// settings hold a sensitive key
+func filePermsFor(path string, settings map[string]interface{}) fs.FileMode {
+	if hasSecrets(settings) {
+		return secretFilePerms
+	}
+
+	if info, err := os.Stat(path); err == nil {
+		return info.Mode().Perm()
+	}
+
+	return filePerms
+}
+
+// hasSecrets reports whether settings set a sensitive key
+func hasSecrets(settings map[string]interface{}) bool {
+	for _, key := range schema {
+		if !key.Sensitive {
+			continue
+		}
+
+		if value, ok := settings[key.Name]; ok && value != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkPermissions warns about files holding a sensitive key that other
+// users can read
+func checkPermissions() {
+	for _, layer := range layers {
+		if !hasSecrets(layer.Values) {
+			continue
+		}
+
+		info, err := os.Stat(layer.Path)
+		if err != nil || info.Mode().Perm()&0o077 == 0 {
+			continue
+		}
+
+		warnFunc(fmt.Sprintf("%s holds secrets and is readable by other users, run \"chmod 600 %s\"", layer.Path, layer.Path))
+	}
+}